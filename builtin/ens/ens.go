@@ -29,7 +29,7 @@ func Resolve(args ...object.Object) object.Object {
 
 	addr, err := resolver.Resolve(args[0].(*object.String).Value)
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 	return &object.Address{Value: addr.String()}
 }
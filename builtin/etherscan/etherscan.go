@@ -9,6 +9,7 @@ import (
 	"github.com/umbracle/go-web3"
 	"github.com/umbracle/go-web3/abi"
 
+	"github.com/umbracle/heura/heura/ethereum"
 	"github.com/umbracle/heura/heura/object"
 )
 
@@ -45,7 +46,7 @@ func getABI(contract string) (*abi.ABI, error) {
 		return nil, err
 	}
 
-	return abi.NewABI(out.Result)
+	return ethereum.NewABI(out.Result)
 }
 
 func getABIBuiltin(args ...object.Object) object.Object {
@@ -57,7 +58,7 @@ func getABIBuiltin(args ...object.Object) object.Object {
 	}
 	val, err := getABI(args[0].(*object.String).Value)
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 	return &object.Contract{Name: "Artifact", ABI: val}
 }
@@ -72,7 +73,7 @@ func getContractBuiltin(args ...object.Object) object.Object {
 	addr := args[0].(*object.String).Value
 	val, err := getABI(addr)
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 	return &object.Instance{Name: "Artifact", Address: web3.HexToAddress(addr), ABI: val}
 }
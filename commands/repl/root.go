@@ -47,7 +47,7 @@ func executor(env *object.Environment) func(s string) {
 		if len(p.Errors()) != 0 {
 			fmt.Printf("  parser errors:\n")
 			for _, msg := range p.Errors() {
-				fmt.Printf("\t" + msg + "\n")
+				fmt.Printf("\t%s\n", msg)
 			}
 		} else {
 			evaluated := evaluator.Eval(program, env)
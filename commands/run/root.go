@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/umbracle/heura/heura/evaluator"
@@ -16,8 +17,14 @@ import (
 )
 
 func init() {
-	RootCmd.Flags().BoolP("dry", "d", false, "build the script with no execution")
+	RootCmd.Flags().BoolP("dry", "d", false, "check the syntax of the script with no execution")
 	RootCmd.Flags().StringP("endpoint", "r", "https://mainnet.infura.io", "rpc endpoint to connect")
+	RootCmd.Flags().Uint64("from-block", 0, "handle the historical events starting at this block")
+	RootCmd.Flags().Uint64("to-block", 0, "handle the events up to this block and exit")
+	RootCmd.Flags().String("state-dir", "", "directory to store the last processed block of each event handler")
+	RootCmd.Flags().String("on-failure", "skip", "action when an event handler fails: skip, retry or stop")
+	RootCmd.Flags().Int("max-retries", 5, "number of retries of a failed event handler with --on-failure retry")
+	RootCmd.Flags().Duration("shutdown-timeout", 30*time.Second, "time to wait for the running event handlers on shutdown")
 }
 
 // RootCmd returns the run command
@@ -29,7 +36,7 @@ var RootCmd = &cobra.Command{
 
 func rootRun(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
-		fmt.Printf("Only one file expected")
+		fmt.Println("Only one file expected")
 		os.Exit(1)
 	}
 
@@ -44,17 +51,20 @@ func rootRun(cmd *cobra.Command, args []string) {
 
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		fmt.Printf(err.Error())
+		fmt.Println(err.Error())
 		os.Exit(0)
 	}
 
-	l := lexer.New(string(data))
+	l := lexer.NewFile(file, string(data))
 	p := parser.New(l)
 
 	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		fmt.Println(p.Errors())
-		os.Exit(0)
+	if errors := p.SyntaxErrors(); len(errors) != 0 {
+		for _, err := range errors {
+			fmt.Println(err.Highlight())
+		}
+		fmt.Printf("%d syntax error(s) found\n", len(errors))
+		os.Exit(1)
 	}
 
 	if ok, _ := cmd.Flags().GetBool("dry"); ok {
@@ -62,31 +72,100 @@ func rootRun(cmd *cobra.Command, args []string) {
 	}
 
 	evaluated := evaluator.Eval(program, env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		// do not start the handlers of a script that failed
+		fmt.Println(errObj.StackTrace())
+		os.Exit(runShutdownHook(env, 1))
+	}
 	if evaluated != nil {
 		fmt.Println(evaluated)
 	}
 
 	events := env.GetOnStatements()
 	if len(events) == 0 {
-		return
+		os.Exit(runShutdownHook(env, 0))
+	}
+
+	config := manager.DefaultConfig()
+	if cmd.Flags().Changed("from-block") {
+		fromBlock, _ := cmd.Flags().GetUint64("from-block")
+		config.FromBlock = &fromBlock
+	}
+	if cmd.Flags().Changed("to-block") {
+		toBlock, _ := cmd.Flags().GetUint64("to-block")
+		config.ToBlock = &toBlock
+	}
+	if config.FromBlock != nil && config.ToBlock != nil && *config.FromBlock > *config.ToBlock {
+		fmt.Println("from-block cannot be higher than to-block")
+		os.Exit(1)
+	}
+
+	config.StateDir, _ = cmd.Flags().GetString("state-dir")
+	config.MaxRetries, _ = cmd.Flags().GetInt("max-retries")
+	config.ShutdownTimeout, _ = cmd.Flags().GetDuration("shutdown-timeout")
+
+	onFailure, _ := cmd.Flags().GetString("on-failure")
+	if config.OnFailure, err = manager.ParseFailurePolicy(onFailure); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	eventManager := manager.NewEventManager(endpoint, env)
+	eventManager, err := manager.NewEventManager(endpoint, env, config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	for _, event := range events {
-		eventManager.Listen(event)
+		if err := eventManager.Listen(event); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	}
+	eventManager.Start()
 
 	handleSignals(eventManager)
 
+	status := 0
+	if err := eventManager.Shutdown(); err != nil {
+		// the handlers are still running, do not run the hook concurrently
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := eventManager.Err(); err != nil {
+		fmt.Printf("script stopped: %v\n", err)
+		status = 1
+	}
+	os.Exit(runShutdownHook(env, status))
 }
 
+// handleSignals waits until the script is interrupted or the event manager
+// is done. A second signal exits without waiting for the running handlers.
 func handleSignals(s *manager.EventManager) {
 	signalCh := make(chan os.Signal, 4)
 	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	select {
 	case <-signalCh:
+		fmt.Println("shutting down, waiting for the running event handlers")
+	case <-s.Done():
 	}
 
-	s.Shutdown()
+	go func() {
+		<-signalCh
+		os.Exit(1)
+	}()
+}
+
+// runShutdownHook runs the 'on shutdown' hook of the script, if any,
+// and returns the exit status
+func runShutdownHook(env *object.Environment, status int) int {
+	hook := env.GetHook("shutdown")
+	if hook == nil {
+		return status
+	}
+	if _, err := evaluator.ApplyHook(hook); err != nil {
+		fmt.Printf("shutdown hook failed: %v\n", err)
+		return 1
+	}
+	return status
 }
@@ -2,6 +2,8 @@ module github.com/umbracle/heura
 
 require (
 	github.com/c-bata/go-prompt v0.2.3
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.4.0
+	github.com/gorilla/websocket v1.4.1
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.1 // indirect
 	github.com/mattn/go-runewidth v0.0.4 // indirect
@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"strings"
 
 	"github.com/umbracle/heura/heura/token"
@@ -11,6 +12,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the node in the source
 }
 
 type Statement interface {
@@ -33,6 +35,13 @@ type LetStatement struct {
 	Value Expression
 }
 
+type AssignStatement struct {
+	Token    token.Token // the assignment token, i.e. = or +=
+	Target   Expression  // Identifier or IndexExpression
+	Operator string
+	Value    Expression
+}
+
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
@@ -50,7 +59,14 @@ type ExpressionStatement struct {
 
 type IntegerLiteral struct {
 	Token token.Token
-	Value int64
+	Value *big.Int
+	Unit  string // unit of the literal if any, i.e. gwei in 5 gwei
+}
+
+// DecimalLiteral is a number with a fractional part, i.e. 1.5
+type DecimalLiteral struct {
+	Token token.Token
+	Value *big.Rat
 }
 
 type BytesLiteral struct {
@@ -87,6 +103,29 @@ type IfExpression struct {
 	Alternative *BlockStatement
 }
 
+type ForStatement struct {
+	Token    token.Token // the 'for' token
+	Key      *Identifier // index of the array or key of the hash, if any
+	Value    *Identifier
+	Iterable Expression
+	End      Expression // end of a numeric range, i.e. for i in 0..10
+	Body     *BlockStatement
+}
+
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
 /*
 type VarLiteral struct {
 	Identifier *Identifier
@@ -95,10 +134,12 @@ type VarLiteral struct {
 */
 
 type ArtifactStatement struct {
+	Token   token.Token // the 'artifact' token
 	Folders []Expression
 }
 
 type ImportStatement struct {
+	Token   token.Token // the 'import' token
 	Folders []Expression
 }
 
@@ -118,11 +159,13 @@ type FunctionLiteral struct {
 func (fl *FunctionLiteral) expressionNode() {}
 
 type OnStatement struct {
-	Contract   *Identifier
-	Method     *Identifier
-	Parameters []*OnIdentifier
-	Body       *BlockStatement
-	Address    Expression // if parsed by address
+	Token         token.Token // the 'on' token
+	Contract      *Identifier // name of the hook if there is no method, i.e. on shutdown {}
+	Method        *Identifier
+	Parameters    []*OnIdentifier
+	Body          *BlockStatement
+	Address       Expression // if parsed by address
+	Confirmations Expression // number of blocks to wait before handling the logs
 }
 
 type OnIdentifier struct {
@@ -141,6 +184,12 @@ type StringLiteral struct {
 	Value string
 }
 
+// InterpolatedString is a string with embedded expressions, i.e. "value ${x}"
+type InterpolatedString struct {
+	Token token.Token  // the string token
+	Parts []Expression // the text as StringLiteral and the embedded expressions
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the `[` token
 	Elements []Expression
@@ -152,6 +201,14 @@ type IndexExpression struct {
 	Index Expression
 }
 
+// SliceExpression is left[start:end], where start and end are optional
+type SliceExpression struct {
+	Token token.Token // The [ token
+	Left  Expression
+	Start Expression
+	End   Expression
+}
+
 type HashLiteral struct {
 	Token token.Token // the `{` token
 	Pairs map[Expression]Expression
@@ -165,6 +222,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -179,6 +243,9 @@ func (as *ArtifactStatement) statementNode() {}
 func (as *ArtifactStatement) TokenLiteral() string {
 	return "ArtifactStatement"
 }
+func (as *ArtifactStatement) Pos() token.Position {
+	return as.Token.Pos
+}
 func (as *ArtifactStatement) String() string {
 	return "ArtifactStatement"
 }
@@ -187,6 +254,9 @@ func (as *ImportStatement) statementNode() {}
 func (as *ImportStatement) TokenLiteral() string {
 	return "ImportStatement"
 }
+func (as *ImportStatement) Pos() token.Position {
+	return as.Token.Pos
+}
 func (as *ImportStatement) String() string {
 	return "ImportStatement"
 }
@@ -195,6 +265,9 @@ func (os *OnStatement) statementNode() {}
 func (os *OnStatement) TokenLiteral() string {
 	return "OnStatement"
 }
+func (os *OnStatement) Pos() token.Position {
+	return os.Token.Pos
+}
 func (os *OnStatement) String() string {
 	return "OnStatement"
 }
@@ -203,6 +276,9 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -220,10 +296,33 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+func (as *AssignStatement) statementNode() {}
+func (as *AssignStatement) TokenLiteral() string {
+	return as.Token.Literal
+}
+func (as *AssignStatement) Pos() token.Position {
+	return as.Token.Pos
+}
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" " + as.Operator + " ")
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -244,6 +343,9 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -263,6 +365,9 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -276,14 +381,34 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
 func (il *IntegerLiteral) String() string {
+	if il.Unit != "" {
+		return il.Token.Literal + " " + il.Unit
+	}
 	return il.Token.Literal
 }
 
+func (dl *DecimalLiteral) expressionNode() {}
+func (dl *DecimalLiteral) TokenLiteral() string {
+	return dl.Token.Literal
+}
+func (dl *DecimalLiteral) Pos() token.Position {
+	return dl.Token.Pos
+}
+func (dl *DecimalLiteral) String() string {
+	return dl.Token.Literal
+}
+
 func (bl *BytesLiteral) expressionNode() {}
 func (bl *BytesLiteral) TokenLiteral() string {
 	return bl.Token.Literal
 }
+func (bl *BytesLiteral) Pos() token.Position {
+	return bl.Token.Pos
+}
 func (bl *BytesLiteral) String() string {
 	return bl.Token.Literal
 }
@@ -292,6 +417,9 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -307,6 +435,9 @@ func (oe *InfixExpression) expressionNode() {}
 func (oe *InfixExpression) TokenLiteral() string {
 	return oe.Token.Literal
 }
+func (oe *InfixExpression) Pos() token.Position {
+	return oe.Token.Pos
+}
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -323,6 +454,9 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -331,6 +465,12 @@ func (me *MultipleExpression) expressionNode() {}
 func (me *MultipleExpression) TokenLiteral() string {
 	return "multiple1"
 }
+func (me *MultipleExpression) Pos() token.Position {
+	if len(me.Expressions) > 0 {
+		return me.Expressions[0].Pos()
+	}
+	return token.Position{}
+}
 func (me *MultipleExpression) String() string {
 	fmt.Println(me.Expressions)
 	return "multiple2"
@@ -340,6 +480,9 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -356,10 +499,80 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	if fs.End != nil {
+		out.WriteString("..")
+		out.WriteString(fs.End.String())
+	}
+	out.WriteString(" ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while ")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BreakStatement) String() string {
+	return bs.Token.Literal + ";"
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+func (cs *ContinueStatement) String() string {
+	return cs.Token.Literal + ";"
+}
+
 func (bs *BlockStatement) expressionNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -374,6 +587,9 @@ func (fl *FunctionLiteral) statementNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -396,6 +612,12 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -414,10 +636,17 @@ func (ce *CallExpression) String() string {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) String() string       { return is.Token.Literal }
+
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -435,6 +664,7 @@ func (al *ArrayLiteral) String() string {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -447,8 +677,30 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
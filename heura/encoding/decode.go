@@ -2,7 +2,9 @@ package encoding
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
+	"strconv"
 
 	"github.com/umbracle/go-web3"
 	"github.com/umbracle/go-web3/abi"
@@ -45,10 +47,16 @@ func Decode(obj object.Object, t abi.Type) (interface{}, error) {
 		return decodeString(obj)
 
 	case abi.KindBytes:
-		return nil, fmt.Errorf("bytes mode not enabled")
+		return decodeBytes(obj)
 
 	case abi.KindArray:
-		return nil, fmt.Errorf("array type not covered")
+		return decodeArray(obj, t)
+
+	case abi.KindTuple:
+		return decodeTuple(obj, t)
+
+	case abi.KindFunction:
+		return decodeFixedBytes(obj, t.GoType())
 
 	default:
 		return nil, fmt.Errorf("Decode type %s not supported", t.String())
@@ -63,16 +71,20 @@ func decodeString(obj object.Object) (interface{}, error) {
 	return obj.(*object.String).Value, nil
 }
 
-func decodeUint(obj object.Object, t abi.Type) (interface{}, error) { // FIX, how to determine uint or not, a funcion in object.Integer, if function fails it is not
+func decodeUint(obj object.Object, t abi.Type) (interface{}, error) {
 	if obj.Type() != object.INTEGER_OBJ {
 		return nil, decodeErr(obj, "uint")
 	}
 
-	if t.Size() == 256 {
-		return obj.(*object.Integer).Value, nil
+	value := obj.(*object.Integer).Value
+	if err := checkIntRange(value, t); err != nil {
+		return nil, err
+	}
+	if t.GoType() == BigInt {
+		return new(big.Int).Set(value), nil
 	}
 
-	return reflect.ValueOf(obj.(*object.Integer).Value.Uint64()).Convert(t.GoType()).Interface(), nil
+	return reflect.ValueOf(value.Uint64()).Convert(t.GoType()).Interface(), nil
 }
 
 func decodeInt(obj object.Object, t abi.Type) (interface{}, error) {
@@ -80,11 +92,37 @@ func decodeInt(obj object.Object, t abi.Type) (interface{}, error) {
 		return nil, decodeErr(obj, "int")
 	}
 
-	if t.Size() == 256 {
-		return obj.(*object.Integer).Value, nil
+	value := obj.(*object.Integer).Value
+	if err := checkIntRange(value, t); err != nil {
+		return nil, err
+	}
+	if t.GoType() == BigInt {
+		return new(big.Int).Set(value), nil
+	}
+
+	return reflect.ValueOf(value.Int64()).Convert(t.GoType()).Interface(), nil
+}
+
+// checkIntRange checks that the value fits in the bits of the int or uint type
+func checkIntRange(value *big.Int, t abi.Type) error {
+	size := uint(t.Size())
+
+	if t.Kind() == abi.KindUInt {
+		if value.Sign() < 0 {
+			return fmt.Errorf("negative value %s for %s", value, t.String())
+		}
+		if value.BitLen() > int(size) {
+			return fmt.Errorf("value %s overflows %s", value, t.String())
+		}
+		return nil
 	}
 
-	return reflect.ValueOf(obj.(*object.Integer).Value.Int64()).Convert(t.GoType()).Interface(), nil
+	// the range of intN is [-2^(N-1), 2^(N-1) - 1]
+	limit := new(big.Int).Lsh(big.NewInt(1), size-1)
+	if value.Cmp(limit) >= 0 || value.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return fmt.Errorf("value %s overflows %s", value, t.String())
+	}
+	return nil
 }
 
 func decodeBool(obj object.Object) (interface{}, error) {
@@ -136,6 +174,90 @@ func decodeSlice(obj object.Object, t abi.Type) (interface{}, error) {
 	return sliceVal.Interface(), nil
 }
 
+func decodeArray(obj object.Object, t abi.Type) (interface{}, error) {
+	if obj.Type() != object.ARRAY_OBJ {
+		return nil, decodeErr(obj, "array")
+	}
+
+	elems := obj.(*object.Array).Elements
+	if len(elems) != t.Size() {
+		return nil, fmt.Errorf("expected %d elements for %s, found %d", t.Size(), t.String(), len(elems))
+	}
+	elemType := *t.Elem()
+
+	arrayVal := reflect.New(t.GoType()).Elem()
+	for i, elt := range elems {
+		v, err := Decode(elt, elemType)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %s", i, err)
+		}
+
+		arrayVal.Index(i).Set(reflect.ValueOf(v))
+	}
+
+	return arrayVal.Interface(), nil
+}
+
+func decodeBytes(obj object.Object) (interface{}, error) {
+	if obj.Type() != object.BYTES_OBJ {
+		return nil, decodeErr(obj, "bytes")
+	}
+
+	return hex.DecodeHex(obj.(*object.Bytes).Value)
+}
+
+// decodeTuple converts a hash with the names of the fields as keys into a
+// tuple. The unnamed fields are found by their index. An array can be used
+// instead with the fields in order.
+func decodeTuple(obj object.Object, t abi.Type) (interface{}, error) {
+	fields := t.TupleElems()
+
+	var values []object.Object
+	switch obj := obj.(type) {
+	case *object.Array:
+		if len(obj.Elements) != len(fields) {
+			return nil, fmt.Errorf("expected %d fields for %s, found %d", len(fields), t.String(), len(obj.Elements))
+		}
+		values = obj.Elements
+
+	case *object.Hash:
+		for indx, field := range fields {
+			var key object.Hashable = &object.String{Value: field.Name}
+			if field.Name == "" {
+				key = &object.Integer{Value: big.NewInt(int64(indx))}
+			}
+			pair, ok := obj.Pairs[key.HashKey()]
+			if !ok {
+				return nil, fmt.Errorf("field %s not found", tupleFieldName(field, indx))
+			}
+			values = append(values, pair.Value)
+		}
+
+	default:
+		return nil, decodeErr(obj, "tuple")
+	}
+
+	res := map[string]interface{}{}
+	for indx, field := range fields {
+		v, err := Decode(values[indx], *field.Elem)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", tupleFieldName(field, indx), err)
+		}
+		res[tupleFieldName(field, indx)] = v
+	}
+
+	return res, nil
+}
+
+// tupleFieldName is the name of the field or its index if it has no name,
+// as used by abi to represent tuples
+func tupleFieldName(field *abi.TupleElem, indx int) string {
+	if field.Name == "" {
+		return strconv.Itoa(indx)
+	}
+	return field.Name
+}
+
 func decodeHash(obj object.Object, t reflect.Type) (interface{}, error) {
 	if obj.Type() != object.BYTES_OBJ {
 		return nil, decodeErr(obj, "hash")
@@ -157,10 +279,20 @@ func decodeHash(obj object.Object, t reflect.Type) (interface{}, error) {
 }
 
 func decodeAddress(obj object.Object, t reflect.Type) (interface{}, error) {
-	if obj.Type() != object.ADDRESS_OBJ {
-		return nil, decodeErr(obj, "address")
+	switch obj := obj.(type) {
+	case *object.Address:
+		return web3.HexToAddress(obj.Value), nil
+
+	case *object.Bytes:
+		// address literals (i.e. 0x...) are parsed as bytes
+		addr, err := obj.ToAddress()
+		if err != nil {
+			return nil, err
+		}
+		return addr.ToAddress(), nil
 	}
-	return web3.HexToAddress(obj.(*object.Address).Value), nil
+
+	return nil, decodeErr(obj, "address")
 }
 
 func decodeErr(obj object.Object, t string) error {
@@ -21,20 +21,25 @@ func Encode(value interface{}, t abi.Type) (object.Object, error) {
 }
 
 func encode(v reflect.Value, t abi.Type) (object.Object, error) {
+	// the values of a tuple are stored as interfaces
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
 	switch t.Kind() {
-	case abi.KindSlice:
+	case abi.KindSlice, abi.KindArray:
 		return encodeSlice(v, t)
 
 	case abi.KindInt:
-		return encodeInt(v)
+		return encodeInt(v, t)
 
 	case abi.KindUInt:
-		return encodeUInt(v)
+		return encodeUInt(v, t)
 
 	case abi.KindBool:
 		return encodeBool(v)
 
-	case abi.KindFixedBytes:
+	case abi.KindFixedBytes, abi.KindFunction, abi.KindBytes:
 		return encodeFixedBytes(v)
 
 	case abi.KindAddress:
@@ -43,11 +48,8 @@ func encode(v reflect.Value, t abi.Type) (object.Object, error) {
 	case abi.KindString:
 		return encodeString(v)
 
-	case abi.KindBytes:
-		return nil, fmt.Errorf("hash type not supported")
-
-	case abi.KindArray:
-		return nil, fmt.Errorf("hash type not supported")
+	case abi.KindTuple:
+		return encodeTuple(v, t)
 	}
 
 	return nil, fmt.Errorf("Encode type %s not supported", t.String())
@@ -62,7 +64,7 @@ func encodeString(v reflect.Value) (object.Object, error) {
 }
 
 func encodeSlice(v reflect.Value, t abi.Type) (object.Object, error) {
-	if v.Kind() != reflect.Slice {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
 		return nil, encodeErr(v, "slice")
 	}
 
@@ -83,6 +85,35 @@ func encodeSlice(v reflect.Value, t abi.Type) (object.Object, error) {
 	}, nil
 }
 
+// encodeTuple converts a tuple into a hash with the names of the fields
+// as keys. The unnamed fields are stored by their index.
+func encodeTuple(v reflect.Value, t abi.Type) (object.Object, error) {
+	if v.Kind() != reflect.Map {
+		return nil, encodeErr(v, "tuple")
+	}
+
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for indx, field := range t.TupleElems() {
+		var key object.Object = &object.String{Value: field.Name}
+		if field.Name == "" {
+			key = &object.Integer{Value: big.NewInt(int64(indx))}
+		}
+
+		value := v.MapIndex(reflect.ValueOf(tupleFieldName(field, indx)))
+		if !value.IsValid() {
+			return nil, fmt.Errorf("field %s not found", tupleFieldName(field, indx))
+		}
+		elem, err := encode(value, *field.Elem)
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Pairs[key.(object.Hashable).HashKey()] = object.HashPair{Key: key, Value: elem}
+	}
+
+	return hash, nil
+}
+
 func encodeAddress(v reflect.Value) (object.Object, error) {
 	data, err := readBytes(v)
 	if err != nil {
@@ -118,34 +149,48 @@ func encodeFixedBytes(v reflect.Value) (object.Object, error) {
 	return &object.Bytes{Value: hex.EncodeToHex(data)}, nil
 }
 
-func encodeInt(v reflect.Value) (object.Object, error) {
+func encodeInt(v reflect.Value, t abi.Type) (object.Object, error) {
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return &object.Integer{Value: big.NewInt(v.Int())}, nil
 
 	case reflect.Ptr:
 		if v.Type() == BigInt {
-			return &object.Integer{Value: v.Interface().(*big.Int)}, nil
+			return encodeBigInt(v, t)
 		}
 	}
 
 	return nil, encodeErr(v, "int")
 }
 
-func encodeUInt(v reflect.Value) (object.Object, error) {
+func encodeUInt(v reflect.Value, t abi.Type) (object.Object, error) {
 	switch v.Kind() {
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return &object.Integer{Value: big.NewInt(int64(v.Uint()))}, nil
+		return &object.Integer{Value: new(big.Int).SetUint64(v.Uint())}, nil
 
 	case reflect.Ptr:
 		if v.Type() == BigInt {
-			return &object.Integer{Value: v.Interface().(*big.Int)}, nil
+			return encodeBigInt(v, t)
 		}
 	}
 
 	return nil, encodeErr(v, "uint")
 }
 
+// encodeBigInt copies the value of the integer types with no native Go type
+// (i.e. uint24, int128) once it is checked that it fits in the type
+func encodeBigInt(v reflect.Value, t abi.Type) (object.Object, error) {
+	if v.IsNil() {
+		return nil, fmt.Errorf("nil value for %s", t.String())
+	}
+
+	value := v.Interface().(*big.Int)
+	if err := checkIntRange(value, t); err != nil {
+		return nil, err
+	}
+	return &object.Integer{Value: new(big.Int).Set(value)}, nil
+}
+
 func encodeBool(v reflect.Value) (object.Object, error) {
 	if v.Kind() != reflect.Bool {
 		return nil, encodeErr(v, "bool")
@@ -1,8 +1,10 @@
 package encoding
 
 import (
+	"math"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/umbracle/go-web3"
@@ -87,6 +89,130 @@ func TestEncoding(t *testing.T) {
 			"uint8",
 			uint8(1),
 		},
+		{
+			&object.Integer{Value: new(big.Int).SetUint64(math.MaxUint64)},
+			"uint64",
+			uint64(math.MaxUint64),
+		},
+		{
+			&object.Integer{Value: big.NewInt(math.MinInt64)},
+			"int64",
+			int64(math.MinInt64),
+		},
+		{
+			&object.Integer{Value: big.NewInt(-128)},
+			"int8",
+			int8(-128),
+		},
+		{
+			&object.Integer{Value: big.NewInt(1<<24 - 1)},
+			"uint24",
+			big.NewInt(1<<24 - 1),
+		},
+		{
+			&object.Integer{Value: new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))},
+			"int128",
+			new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127)),
+		},
+		{
+			&object.Bytes{Value: "0x010203"},
+			"bytes",
+			[]byte{1, 2, 3},
+		},
+		{
+			&object.Bytes{Value: "0x"},
+			"bytes",
+			[]byte{},
+		},
+		{
+			&object.Array{
+				Elements: []object.Object{
+					&object.Bytes{Value: "0x01"},
+					&object.Bytes{Value: "0x0203"},
+				},
+			},
+			"bytes[]",
+			[][]byte{[]byte{1}, []byte{2, 3}},
+		},
+		{
+			&object.Array{
+				Elements: []object.Object{
+					&object.Integer{Value: big.NewInt(1)},
+					&object.Integer{Value: big.NewInt(2)},
+					&object.Integer{Value: big.NewInt(3)},
+				},
+			},
+			"uint256[3]",
+			[3]*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+		},
+		{
+			&object.Array{
+				Elements: []object.Object{
+					&object.Array{
+						Elements: []object.Object{
+							&object.Address{Value: Address},
+						},
+					},
+					&object.Array{
+						Elements: []object.Object{},
+					},
+				},
+			},
+			"address[][2]",
+			[2][]web3.Address{[]web3.Address{web3.HexToAddress(Address)}, []web3.Address{}},
+		},
+		{
+			testHash(
+				"a", &object.Integer{Value: big.NewInt(1)},
+				"b", &object.Address{Value: Address},
+			),
+			"tuple(a uint256, b address)",
+			map[string]interface{}{
+				"a": big.NewInt(1),
+				"b": web3.HexToAddress(Address),
+			},
+		},
+		{
+			testHash(
+				0, &object.Boolean{Value: true},
+				1, &object.String{Value: "x"},
+			),
+			"tuple(bool, string)",
+			map[string]interface{}{
+				"0": true,
+				"1": "x",
+			},
+		},
+		{
+			testHash(
+				"id", &object.Integer{Value: big.NewInt(1)},
+				"owner", testHash(
+					"addr", &object.Address{Value: Address},
+					"data", &object.Bytes{Value: "0x01"},
+				),
+			),
+			"tuple(id uint8, owner tuple(addr address, data bytes))",
+			map[string]interface{}{
+				"id": uint8(1),
+				"owner": map[string]interface{}{
+					"addr": web3.HexToAddress(Address),
+					"data": []byte{1},
+				},
+			},
+		},
+		{
+			&object.Array{
+				Elements: []object.Object{
+					testHash("a", &object.Integer{Value: big.NewInt(1)}),
+					testHash("a", &object.Integer{Value: big.NewInt(2)}),
+				},
+			},
+			"tuple(a int256)[]",
+			[]map[string]interface{}{
+				{"a": big.NewInt(1)},
+				{"a": big.NewInt(2)},
+			},
+		},
 	}
 
 	for _, cc := range cases {
@@ -102,7 +228,7 @@ func TestEncoding(t *testing.T) {
 			}
 
 			if !reflect.DeepEqual(obj, cc.Output) {
-				t.Fatal("bad decoding")
+				t.Fatalf("bad decoding: %#v", obj)
 			}
 
 			obj2, err := Encode(obj, *ttt)
@@ -116,3 +242,235 @@ func TestEncoding(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeErrors(t *testing.T) {
+	var cases = []struct {
+		Input object.Object
+		Type  string
+		Err   string
+	}{
+		{
+			&object.Integer{Value: big.NewInt(256)},
+			"uint8",
+			"value 256 overflows uint8",
+		},
+		{
+			&object.Integer{Value: big.NewInt(-1)},
+			"uint256",
+			"negative value -1 for uint256",
+		},
+		{
+			&object.Integer{Value: big.NewInt(128)},
+			"int8",
+			"value 128 overflows int8",
+		},
+		{
+			&object.Integer{Value: big.NewInt(-129)},
+			"int8",
+			"value -129 overflows int8",
+		},
+		{
+			&object.Integer{Value: new(big.Int).Lsh(big.NewInt(1), 64)},
+			"uint64",
+			"value 18446744073709551616 overflows uint64",
+		},
+		{
+			&object.Integer{Value: big.NewInt(1 << 24)},
+			"uint24",
+			"value 16777216 overflows uint24",
+		},
+		{
+			&object.Integer{Value: new(big.Int).Lsh(big.NewInt(1), 255)},
+			"int256",
+			"value 57896044618658097711785492504343953926634992332820282019728792003956564819968 overflows int256",
+		},
+		{
+			&object.String{Value: "0x01"},
+			"bytes",
+			"failed to decode STRING as bytes",
+		},
+		{
+			&object.Array{Elements: []object.Object{&object.Integer{Value: big.NewInt(1)}}},
+			"uint256[2]",
+			"expected 2 elements for uint256[2], found 1",
+		},
+		{
+			&object.Array{Elements: []object.Object{&object.Boolean{Value: true}}},
+			"uint256[1]",
+			"element 0: failed to decode BOOLEAN as uint",
+		},
+		{
+			testHash("a", &object.Integer{Value: big.NewInt(1)}),
+			"tuple(a uint256, b uint256)",
+			"field b not found",
+		},
+		{
+			testHash("a", &object.String{Value: "1"}),
+			"tuple(a uint256)",
+			"field a: failed to decode STRING as uint",
+		},
+		{
+			&object.Array{Elements: []object.Object{}},
+			"tuple(a uint256)",
+			"expected 1 fields for (uint256), found 0",
+		},
+		{
+			&object.Integer{Value: big.NewInt(1)},
+			"tuple(a uint256)",
+			"failed to decode INTEGER as tuple",
+		},
+	}
+
+	for _, cc := range cases {
+		t.Run(cc.Type, func(t *testing.T) {
+			typ, err := abi.NewType(cc.Type)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Decode(cc.Input, *typ)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.Error() != cc.Err {
+				t.Fatalf("expected %q but found %q", cc.Err, err.Error())
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	var cases = []struct {
+		Input interface{}
+		Type  string
+		Err   string
+	}{
+		{
+			big.NewInt(1 << 24),
+			"uint24",
+			"value 16777216 overflows uint24",
+		},
+		{
+			big.NewInt(-1),
+			"uint128",
+			"negative value -1 for uint128",
+		},
+		{
+			new(big.Int).Lsh(big.NewInt(1), 127),
+			"int128",
+			"value 170141183460469231731687303715884105728 overflows int128",
+		},
+		{
+			"1",
+			"uint256",
+			"failed to encode string as uint",
+		},
+	}
+
+	for _, cc := range cases {
+		t.Run(cc.Type, func(t *testing.T) {
+			typ, err := abi.NewType(cc.Type)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Encode(cc.Input, *typ)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if err.Error() != cc.Err {
+				t.Fatalf("expected %q but found %q", cc.Err, err.Error())
+			}
+		})
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	var cases = []struct {
+		Types []string
+		Args  []object.Object
+	}{
+		{
+			[]string{"uint8", "int16", "uint64", "int64", "uint96", "int24"},
+			[]object.Object{
+				&object.Integer{Value: big.NewInt(255)},
+				&object.Integer{Value: big.NewInt(-32768)},
+				&object.Integer{Value: new(big.Int).SetUint64(math.MaxUint64)},
+				&object.Integer{Value: big.NewInt(-1)},
+				&object.Integer{Value: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 96), big.NewInt(1))},
+				&object.Integer{Value: big.NewInt(-(1 << 23))},
+			},
+		},
+		{
+			[]string{"bytes", "uint256[2]"},
+			[]object.Object{
+				&object.Bytes{Value: "0x" + strings.Repeat("ab", 40)},
+				&object.Array{
+					Elements: []object.Object{
+						&object.Integer{Value: big.NewInt(1)},
+						&object.Integer{Value: big.NewInt(2)},
+					},
+				},
+			},
+		},
+		{
+			[]string{"tuple(a string, b tuple(c bytes, d uint256[]))", "bool"},
+			[]object.Object{
+				testHash(
+					"a", &object.String{Value: "heura"},
+					"b", testHash(
+						"c", &object.Bytes{Value: "0x0102"},
+						"d", &object.Array{
+							Elements: []object.Object{
+								&object.Integer{Value: big.NewInt(3)},
+							},
+						},
+					),
+				),
+				&object.Boolean{Value: true},
+			},
+		},
+	}
+
+	for _, cc := range cases {
+		t.Run("", func(t *testing.T) {
+			arguments := abi.Arguments{}
+			for _, typ := range cc.Types {
+				tt, err := abi.NewType(typ)
+				if err != nil {
+					t.Fatal(err)
+				}
+				arguments = append(arguments, &abi.Argument{Type: tt})
+			}
+
+			data, err := Pack(arguments, cc.Args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := Unpack(arguments, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res, cc.Args) {
+				t.Fatal("bad round trip")
+			}
+		})
+	}
+}
+
+// testHash creates a hash with the keys and values in kv. The keys are
+// either strings or ints.
+func testHash(kv ...interface{}) *object.Hash {
+	hash := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for i := 0; i < len(kv); i += 2 {
+		var key object.Hashable
+		switch k := kv[i].(type) {
+		case string:
+			key = &object.String{Value: k}
+		case int:
+			key = &object.Integer{Value: big.NewInt(int64(k))}
+		}
+		hash.Pairs[key.HashKey()] = object.HashPair{Key: key.(object.Object), Value: kv[i+1].(object.Object)}
+	}
+	return hash
+}
@@ -0,0 +1,195 @@
+package ethereum
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/umbracle/go-web3/abi"
+
+	"github.com/umbracle/heura/heura/encoding"
+	"github.com/umbracle/heura/heura/object"
+)
+
+// NewABI parses an ABI. The abi package stores the methods and events by name,
+// so only the last overload is kept. Here, every method and event is stored
+// with its signature as key too, i.e. "transfer(address,uint256)".
+func NewABI(content string) (*abi.ABI, error) {
+	res, err := abi.NewABI(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []struct {
+		Type      string
+		Name      string
+		Constant  bool
+		Anonymous bool
+		Inputs    abi.Arguments
+		Outputs   abi.Arguments
+	}
+	if err := json.Unmarshal([]byte(content), &fields); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		switch field.Type {
+		case "function", "":
+			method := &abi.Method{
+				Name:    field.Name,
+				Const:   field.Constant,
+				Inputs:  field.Inputs,
+				Outputs: field.Outputs,
+			}
+			res.Methods[method.Sig()] = method
+
+		case "event":
+			event := &abi.Event{
+				Name:      field.Name,
+				Anonymous: field.Anonymous,
+				Inputs:    field.Inputs,
+			}
+			res.Events[event.Sig()] = event
+		}
+	}
+	return res, nil
+}
+
+// isSignature returns whether the name is a signature instead of a name
+func isSignature(name string) bool {
+	return strings.Contains(name, "(")
+}
+
+// normalizeSignature removes the spaces of a signature
+func normalizeSignature(sig string) string {
+	return strings.Join(strings.Fields(sig), "")
+}
+
+// FindMethod returns the method called name for the arguments. The name is
+// either a signature, i.e. "transfer(address,uint256)", or the name of the
+// method. If the method is overloaded, the overload is chosen by the number of
+// arguments and then by their types.
+func FindMethod(a *abi.ABI, name string, args []object.Object) (*abi.Method, error) {
+	if isSignature(name) {
+		sig := normalizeSignature(name)
+		for _, method := range a.Methods {
+			if method.Sig() == sig {
+				return method, nil
+			}
+		}
+		return nil, fmt.Errorf("method %s not found", sig)
+	}
+
+	candidates := map[string]*abi.Method{}
+	for _, method := range a.Methods {
+		if method.Name == name {
+			candidates[method.Sig()] = method
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("method %s not found", name)
+	}
+
+	// filter by the number of arguments
+	byCount := []*abi.Method{}
+	for _, method := range candidates {
+		if len(method.Inputs) == len(args) {
+			byCount = append(byCount, method)
+		}
+	}
+	if len(byCount) == 0 {
+		if len(candidates) == 1 {
+			for _, method := range candidates {
+				return nil, fmt.Errorf("method %s expects %d arguments, found %d", method.Sig(), len(method.Inputs), len(args))
+			}
+		}
+		return nil, fmt.Errorf("no overload of %s takes %d arguments, found %s", name, len(args), methodSigs(candidates))
+	}
+	if len(byCount) == 1 {
+		// the arguments are checked when they are encoded
+		return byCount[0], nil
+	}
+
+	// filter by the types of the arguments
+	byType := map[string]*abi.Method{}
+	for _, method := range byCount {
+		if argumentsMatch(method.Inputs, args) {
+			byType[method.Sig()] = method
+		}
+	}
+	switch len(byType) {
+	case 0:
+		return nil, fmt.Errorf("no overload of %s matches the arguments, found %s", name, methodSigs(candidates))
+	case 1:
+		for _, method := range byType {
+			return method, nil
+		}
+	}
+	return nil, fmt.Errorf("ambiguous call to %s, use one of %s as in contract[\"sig\"](...)", name, methodSigs(byType))
+}
+
+// argumentsMatch returns whether the arguments can be encoded with the types
+func argumentsMatch(inputs abi.Arguments, args []object.Object) bool {
+	for indx, input := range inputs {
+		if _, err := encoding.Decode(args[indx], *input.Type); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func methodSigs(methods map[string]*abi.Method) string {
+	sigs := []string{}
+	for sig := range methods {
+		sigs = append(sigs, sig)
+	}
+	sort.Strings(sigs)
+	return strings.Join(sigs, ", ")
+}
+
+// FindEvent returns the event called name with the given number of parameters.
+// The name is either a signature, i.e. "Transfer(address,address,uint256)",
+// or the name of the event.
+func FindEvent(a *abi.ABI, name string, params int) (*abi.Event, error) {
+	if isSignature(name) {
+		sig := normalizeSignature(name)
+		for _, event := range a.Events {
+			if event.Sig() == sig {
+				return event, nil
+			}
+		}
+		return nil, fmt.Errorf("event %s not found", sig)
+	}
+
+	candidates := map[string]*abi.Event{}
+	for _, event := range a.Events {
+		if event.Name == name {
+			candidates[event.Sig()] = event
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("event %s not found", name)
+	}
+
+	matches := []string{}
+	for sig, event := range candidates {
+		if len(event.Inputs) == params {
+			matches = append(matches, sig)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		if len(candidates) == 1 {
+			for _, event := range candidates {
+				return nil, fmt.Errorf("event %s has %d parameters, found %d", event.Sig(), len(event.Inputs), params)
+			}
+		}
+		return nil, fmt.Errorf("no overload of event %s has %d parameters", name, params)
+	case 1:
+		return candidates[matches[0]], nil
+	}
+	return nil, fmt.Errorf("ambiguous event %s, use one of %s as in on Contract[\"sig\"](...)", name, strings.Join(matches, ", "))
+}
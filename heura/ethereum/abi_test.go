@@ -0,0 +1,122 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/umbracle/heura/heura/object"
+)
+
+const overloadedABI = `[
+	{"type": "function", "name": "safeTransferFrom", "inputs": [
+		{"name": "from", "type": "address"}, {"name": "to", "type": "address"}, {"name": "id", "type": "uint256"}
+	]},
+	{"type": "function", "name": "safeTransferFrom", "inputs": [
+		{"name": "from", "type": "address"}, {"name": "to", "type": "address"}, {"name": "id", "type": "uint256"}, {"name": "data", "type": "bytes"}
+	]},
+	{"type": "function", "name": "set", "inputs": [{"name": "value", "type": "uint8"}]},
+	{"type": "function", "name": "set", "inputs": [{"name": "value", "type": "string"}]},
+	{"type": "function", "name": "set", "inputs": [{"name": "value", "type": "int256"}]},
+	{"type": "function", "name": "name", "inputs": []},
+	{"type": "event", "name": "Transfer", "inputs": [
+		{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}
+	]},
+	{"type": "event", "name": "Transfer", "inputs": [
+		{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}, {"name": "data", "type": "bytes"}
+	]},
+	{"type": "event", "name": "Approval", "inputs": [
+		{"name": "owner", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}
+	]},
+	{"type": "event", "name": "Approval", "inputs": [
+		{"name": "owner", "type": "address", "indexed": true}, {"name": "value", "type": "int256"}
+	]}
+]`
+
+var (
+	testAddr = &object.Address{Value: "0x1111111111111111111111111111111111111111"}
+	testInt  = &object.Integer{Value: big.NewInt(1)}
+)
+
+func TestFindMethod(t *testing.T) {
+	a, err := NewABI(overloadedABI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		args     []object.Object
+		expected string
+		err      string
+	}{
+		{"safeTransferFrom", []object.Object{testAddr, testAddr, testInt}, "safeTransferFrom(address,address,uint256)", ""},
+		{"safeTransferFrom", []object.Object{testAddr, testAddr, testInt, &object.Bytes{Value: "0x01"}}, "safeTransferFrom(address,address,uint256,bytes)", ""},
+		{"safeTransferFrom(address, address, uint256, bytes)", nil, "safeTransferFrom(address,address,uint256,bytes)", ""},
+		{"set", []object.Object{&object.String{Value: "a"}}, "set(string)", ""},
+		{"set", []object.Object{&object.Integer{Value: big.NewInt(-1)}}, "set(int256)", ""},
+		{"set", []object.Object{&object.Integer{Value: big.NewInt(1000)}}, "set(int256)", ""},
+		{"set(uint8)", []object.Object{testInt}, "set(uint8)", ""},
+		{"name", nil, "name()", ""},
+		{"name", []object.Object{testInt}, "", "method name() expects 0 arguments, found 1"},
+		{"other", nil, "", "method other not found"},
+		{"set(address)", nil, "", "method set(address) not found"},
+		{"safeTransferFrom", []object.Object{testAddr}, "", "no overload of safeTransferFrom takes 1 arguments, found safeTransferFrom(address,address,uint256), safeTransferFrom(address,address,uint256,bytes)"},
+		{"set", []object.Object{&object.Boolean{Value: true}}, "", "no overload of set matches the arguments, found set(int256), set(string), set(uint8)"},
+		{"set", []object.Object{testInt}, "", `ambiguous call to set, use one of set(int256), set(uint8) as in contract["sig"](...)`},
+	}
+
+	for _, c := range cases {
+		method, err := FindMethod(a, c.name, c.args)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: expected error %q but found %v", c.name, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if method.Sig() != c.expected {
+			t.Errorf("%s: expected %s but found %s", c.name, c.expected, method.Sig())
+		}
+	}
+}
+
+func TestFindEvent(t *testing.T) {
+	a, err := NewABI(overloadedABI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		params   int
+		expected string
+		err      string
+	}{
+		{"Transfer", 3, "Transfer(address,address,uint256)", ""},
+		{"Transfer", 4, "Transfer(address,address,uint256,bytes)", ""},
+		{"Approval(address,int256)", 2, "Approval(address,int256)", ""},
+		{"Transfer", 2, "", "no overload of event Transfer has 2 parameters"},
+		{"Approval", 2, "", `ambiguous event Approval, use one of Approval(address,int256), Approval(address,uint256) as in on Contract["sig"](...)`},
+		{"Other", 0, "", "event Other not found"},
+	}
+
+	for _, c := range cases {
+		event, err := FindEvent(a, c.name, c.params)
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("%s: expected error %q but found %v", c.name, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if event.Sig() != c.expected {
+			t.Errorf("%s: expected %s but found %s", c.name, c.expected, event.Sig())
+		}
+	}
+}
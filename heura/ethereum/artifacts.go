@@ -31,7 +31,7 @@ func readFileArtifact(path string) (*abi.ABI, error) {
 	if err != nil {
 		return nil, err
 	}
-	return abi.NewABI(string(data))
+	return NewABI(string(data))
 }
 
 func ReadArtifacts(exprs []ast.Expression) (map[string]*abi.ABI, error) {
@@ -90,12 +90,7 @@ func ReadArtifacts(exprs []ast.Expression) (map[string]*abi.ABI, error) {
 }
 
 func ReadArtifact(content string) (*abi.ABI, error) {
-	artifact := &abi.ABI{}
-	if err := artifact.UnmarshalJSON([]byte(content)); err != nil {
-		return nil, err
-	}
-
-	return artifact, nil
+	return NewABI(content)
 }
 
 func ReadBuiltInArtifact(name string) (*abi.ABI, error) {
@@ -118,49 +118,137 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 
-	"Account": &object.Builtin{
+	"format":     &object.Builtin{Fn: builtinFormat},
+	"split":      &object.Builtin{Fn: builtinSplit},
+	"join":       &object.Builtin{Fn: builtinJoin},
+	"contains":   &object.Builtin{Fn: builtinContains},
+	"startsWith": &object.Builtin{Fn: builtinStartsWith},
+	"endsWith":   &object.Builtin{Fn: builtinEndsWith},
+	"replace":    &object.Builtin{Fn: builtinReplace},
+	"trim":       &object.Builtin{Fn: builtinTrim},
+	"upper":      &object.Builtin{Fn: builtinUpper},
+	"lower":      &object.Builtin{Fn: builtinLower},
+	"substring":  &object.Builtin{Fn: builtinSubstring},
+	"toInt":      &object.Builtin{Fn: builtinToInt},
+	"toString":   &object.Builtin{Fn: builtinToString},
+
+	"keys":   &object.Builtin{Fn: builtinKeys},
+	"values": &object.Builtin{Fn: builtinValues},
+	"delete": &object.Builtin{Fn: builtinDelete},
+	"merge":  &object.Builtin{Fn: builtinMerge},
+
+	"Account": &object.Builtin{Fn: builtinAccount},
+
+	"kwei":   conv(3),
+	"mwei":   conv(6),
+	"gwei":   conv(9),
+	"szabo":  conv(12),
+	"finney": conv(15),
+	"ether":  conv(18),
+
+	// formatUnits(value, decimals) returns the decimal amount of an integer
+	// value with the given decimals, i.e. formatUnits(1500, 3) is 1.5
+	"formatUnits": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("expected one parameter but found %d", len(args))
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
 			}
 
-			account, err := object.NewAccount(args[0])
+			value, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `formatUnits` must be INTEGER, got %s", args[0].Type())
+			}
+			decimals, err := unitDecimals(args[1])
 			if err != nil {
-				return newError(err.Error())
+				return newError("%v", err)
 			}
 
-			return account
+			res := new(big.Rat).SetFrac(value.Value, unitScale(decimals))
+			return &object.Decimal{Value: res}
 		},
 	},
 
-	"kwei":   conv(3),
-	"mwei":   conv(6),
-	"gwei":   conv(9),
-	"szabo":  conv(12),
-	"finney": conv(15),
-	"ether":  conv(18),
+	// parseUnits(value, decimals) returns the integer value of a decimal
+	// amount with the given decimals, i.e. parseUnits("1.5", 3) is 1500
+	"parseUnits": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			var value *big.Rat
+			switch arg := args[0].(type) {
+			case *object.String:
+				v, ok := new(big.Rat).SetString(arg.Value)
+				if !ok {
+					return newError("could not parse %q as a number", arg.Value)
+				}
+				value = v
+			case *object.Integer, *object.Decimal:
+				value = toRat(arg)
+			default:
+				return newError("argument to `parseUnits` must be STRING, INTEGER or DECIMAL, got %s", args[0].Type())
+			}
+
+			decimals, err := unitDecimals(args[1])
+			if err != nil {
+				return newError("%v", err)
+			}
+
+			res, err := scaleUnits(value, decimals)
+			if err != nil {
+				return newError("%v", err)
+			}
+			return &object.Integer{Value: res}
+		},
+	},
 }
 
-func conv(dec uint64) *object.Builtin {
-	mul := new(big.Int).SetUint64(10)
-	mul = mul.Exp(mul, new(big.Int).SetUint64(dec), nil)
+// maxUnitDecimals is the largest number of decimals of a token, which is an uint8
+const maxUnitDecimals = 255
 
+func unitDecimals(obj object.Object) (uint64, error) {
+	decimals, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, fmt.Errorf("decimals must be INTEGER, got %s", obj.Type())
+	}
+	if decimals.Value.Sign() < 0 || decimals.Value.Cmp(big.NewInt(maxUnitDecimals)) > 0 {
+		return 0, fmt.Errorf("decimals must be between 0 and %d, got %s", maxUnitDecimals, decimals.Value)
+	}
+	return decimals.Value.Uint64(), nil
+}
+
+// unitScale returns 10^decimals
+func unitScale(decimals uint64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), new(big.Int).SetUint64(decimals), nil)
+}
+
+// scaleUnits returns the value multiplied by 10^decimals, which has to be
+// an integer
+func scaleUnits(value *big.Rat, decimals uint64) (*big.Int, error) {
+	res := new(big.Rat).Mul(value, new(big.Rat).SetInt(unitScale(decimals)))
+	if !res.IsInt() {
+		return nil, fmt.Errorf("%s has more than %d decimals", (&object.Decimal{Value: value}).Inspect(), decimals)
+	}
+	return new(big.Int).Set(res.Num()), nil
+}
+
+func conv(dec uint64) *object.Builtin {
 	return &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("expected one parameter but found %d", len(args))
 			}
 
-			if args[0].Type() != object.INTEGER_OBJ {
+			if !isNumber(args[0]) {
 				return newError("expected number, got %s", args[0].Type())
 			}
 
-			v := args[0].(*object.Integer).Value
-
-			vv := new(big.Int).Set(v)
-			vv = vv.Mul(vv, mul)
-
-			return &object.Integer{Value: vv}
+			res, err := scaleUnits(toRat(args[0]), dec)
+			if err != nil {
+				return newError("%v", err)
+			}
+			return &object.Integer{Value: res}
 		},
 	}
 }
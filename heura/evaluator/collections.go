@@ -0,0 +1,367 @@
+package evaluator
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/umbracle/heura/heura/object"
+)
+
+func init() {
+	// the builtins that call back into the evaluator are registered here,
+	// the builtins map cannot refer to Eval without an initialization cycle
+	builtins["map"] = &object.Builtin{Fn: builtinMap}
+	builtins["filter"] = &object.Builtin{Fn: builtinFilter}
+	builtins["reduce"] = &object.Builtin{Fn: builtinReduce}
+	builtins["sort"] = &object.Builtin{Fn: builtinSort}
+}
+
+// listElements returns the elements of an array or of the values returned
+// by a call with multiple outputs
+func listElements(name string, obj object.Object) ([]object.Object, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Elements, nil
+	case *object.Multiple:
+		return obj.Values, nil
+	default:
+		return nil, newError("argument to `%s` must be ARRAY, got %s", name, obj.Type())
+	}
+}
+
+func callbackArg(name string, obj object.Object) *object.Error {
+	if obj.Type() != object.FUNCTION_OBJ && obj.Type() != object.BUILTIN_OBJ {
+		return newError("argument to `%s` must be FUNCTION, got %s", name, obj.Type())
+	}
+	return nil
+}
+
+// callback calls fn with the arguments and as many of the optional ones
+// as it takes, i.e. the callback of map can take the element or the element
+// and its index. Builtins only get the arguments
+func callback(fn object.Object, args []object.Object, optional ...object.Object) object.Object {
+	if f, ok := fn.(*object.Function); ok {
+		for i := 0; i < len(optional) && len(args) < len(f.Parameters); i++ {
+			args = append(args, optional[i])
+		}
+	}
+
+	// the environment is only used to call contracts, which are not callbacks
+	res := ApplyFunction(nil, fn, args)
+	if res == nil {
+		return NULL
+	}
+	return res
+}
+
+// map(array, fn) returns the result of fn(element, index) for each element
+func builtinMap(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	elements, err := listElements("map", args[0])
+	if err != nil {
+		return err
+	}
+	if err := callbackArg("map", args[1]); err != nil {
+		return err
+	}
+
+	res := make([]object.Object, len(elements))
+	for i, elem := range elements {
+		val := callback(args[1], []object.Object{elem}, newInteger(i))
+		if isError(val) {
+			return val
+		}
+		res[i] = val
+	}
+	return &object.Array{Elements: res}
+}
+
+// filter(array, fn) returns the elements for which fn(element, index) is truthy
+func builtinFilter(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	elements, err := listElements("filter", args[0])
+	if err != nil {
+		return err
+	}
+	if err := callbackArg("filter", args[1]); err != nil {
+		return err
+	}
+
+	res := []object.Object{}
+	for i, elem := range elements {
+		val := callback(args[1], []object.Object{elem}, newInteger(i))
+		if isError(val) {
+			return val
+		}
+		if isTruthy(val) {
+			res = append(res, elem)
+		}
+	}
+	return &object.Array{Elements: res}
+}
+
+// reduce(array, fn, initial) accumulates fn(acc, element, index) over the
+// elements. Without initial, the first element is the initial value
+func builtinReduce(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	elements, err := listElements("reduce", args[0])
+	if err != nil {
+		return err
+	}
+	if err := callbackArg("reduce", args[1]); err != nil {
+		return err
+	}
+
+	var acc object.Object
+	start := 0
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("reduce of empty array with no initial value")
+		}
+		acc = elements[0]
+		start = 1
+	}
+
+	for i := start; i < len(elements); i++ {
+		acc = callback(args[1], []object.Object{acc, elements[i]}, newInteger(i))
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// sort(array) returns a sorted copy of an array of numbers or strings.
+// sort(array, fn) sorts with fn(a, b), which is true if a goes before b
+func builtinSort(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	elements, err := listElements("sort", args[0])
+	if err != nil {
+		return err
+	}
+
+	less := func(a, b object.Object) (bool, *object.Error) {
+		cmp, err := compareObjects(a, b)
+		return cmp < 0, err
+	}
+	if len(args) == 2 {
+		if err := callbackArg("sort", args[1]); err != nil {
+			return err
+		}
+		less = func(a, b object.Object) (bool, *object.Error) {
+			val := callback(args[1], []object.Object{a, b})
+			if isError(val) {
+				return false, val.(*object.Error)
+			}
+			res, ok := val.(*object.Boolean)
+			if !ok {
+				return false, newError("comparator of `sort` must return BOOLEAN, got %s", val.Type())
+			}
+			return res.Value, nil
+		}
+	}
+
+	res := make([]object.Object, len(elements))
+	copy(res, elements)
+
+	// the first error stops the comparisons
+	var sortErr *object.Error
+	sort.SliceStable(res, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		ok, err := less(res[i], res[j])
+		if err != nil {
+			sortErr = err
+		}
+		return ok
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	return &object.Array{Elements: res}
+}
+
+// compareObjects compares two numbers or two strings
+func compareObjects(a, b object.Object) (int, *object.Error) {
+	switch {
+	case isNumber(a) && isNumber(b):
+		return toRat(a).Cmp(toRat(b)), nil
+	case a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ:
+		return strings.Compare(a.(*object.String).Value, b.(*object.String).Value), nil
+	default:
+		return 0, newError("cannot compare %s and %s", a.Type(), b.Type())
+	}
+}
+
+// objectsEqual compares two values by value instead of by reference. An
+// address is equal to the 20 bytes literal of the same address
+func objectsEqual(a, b object.Object) bool {
+	switch {
+	case isNumber(a) && isNumber(b):
+		return toRat(a).Cmp(toRat(b)) == 0
+	case a.Type() == object.ADDRESS_OBJ && b.Type() == object.BYTES_OBJ:
+		return addressEqualsBytes(a.(*object.Address), b.(*object.Bytes))
+	case a.Type() == object.BYTES_OBJ && b.Type() == object.ADDRESS_OBJ:
+		return addressEqualsBytes(b.(*object.Address), a.(*object.Bytes))
+	case a.Type() != b.Type():
+		return false
+	}
+
+	switch a := a.(type) {
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.Bytes:
+		return strings.EqualFold(a.Value, b.(*object.Bytes).Value)
+	case *object.Address:
+		return strings.EqualFold(a.Value, b.(*object.Address).Value)
+	case *object.Null:
+		return true
+	default:
+		return a == b
+	}
+}
+
+// addressEqualsBytes compares an address with a literal, i.e. 0x11...11,
+// which is parsed as bytes
+func addressEqualsBytes(a *object.Address, b *object.Bytes) bool {
+	addr, err := b.ToAddress()
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(a.Value, addr.Value)
+}
+
+// contains(s, substr) reports whether substr is within the string s,
+// contains(array, x) whether x is an element of the array and
+// contains(hash, key) whether the hash has the key
+func builtinContains(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	switch obj := args[0].(type) {
+	case *object.String:
+		substr, ok := args[1].(*object.String)
+		if !ok {
+			return newError("argument to `contains` must be STRING, got %s", args[1].Type())
+		}
+		return nativeBoolToBooleanObject(strings.Contains(obj.Value, substr.Value))
+
+	case *object.Array, *object.Multiple:
+		elements, _ := listElements("contains", obj)
+		for _, elem := range elements {
+			if objectsEqual(elem, args[1]) {
+				return TRUE
+			}
+		}
+		return FALSE
+
+	case *object.Hash:
+		key, ok := args[1].(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", args[1].Type())
+		}
+		_, ok = obj.Pairs[key.HashKey()]
+		return nativeBoolToBooleanObject(ok)
+
+	default:
+		return newError("argument to `contains` not supported, got %s", args[0].Type())
+	}
+}
+
+// keys(hash) returns the keys of the hash in the order used by for loops
+func builtinKeys(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+	}
+
+	res := []object.Object{}
+	for _, pair := range sortedPairs(hash) {
+		res = append(res, pair.Key)
+	}
+	return &object.Array{Elements: res}
+}
+
+// values(hash) returns the values of the hash in the order of their keys
+func builtinValues(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError("argument to `values` must be HASH, got %s", args[0].Type())
+	}
+
+	res := []object.Object{}
+	for _, pair := range sortedPairs(hash) {
+		res = append(res, pair.Value)
+	}
+	return &object.Array{Elements: res}
+}
+
+// delete(hash, key) removes the key from the hash and returns its value
+func builtinDelete(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError("argument to `delete` must be HASH, got %s", args[0].Type())
+	}
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+
+	pair, ok := hash.Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+	delete(hash.Pairs, key.HashKey())
+	return pair.Value
+}
+
+// merge(hashes...) returns a new hash with the pairs of all the hashes. The
+// later hashes overwrite the keys of the previous ones
+func builtinMerge(args ...object.Object) object.Object {
+	res := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+	for _, arg := range args {
+		hash, ok := arg.(*object.Hash)
+		if !ok {
+			return newError("argument to `merge` must be HASH, got %s", arg.Type())
+		}
+		for k, pair := range hash.Pairs {
+			res.Pairs[k] = pair
+		}
+	}
+	return res
+}
+
+func newInteger(i int) *object.Integer {
+	return &object.Integer{Value: big.NewInt(int64(i))}
+}
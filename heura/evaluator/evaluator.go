@@ -4,6 +4,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"sort"
+	"strings"
 
 	"github.com/umbracle/go-web3"
 	"github.com/umbracle/go-web3/abi"
@@ -19,12 +21,24 @@ import (
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Eval evaluates the node. An error is annotated with the position of
+// the innermost node that failed.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
@@ -46,7 +60,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ArtifactStatement:
 		abis, err := ethereum.ReadArtifacts(node.Folders)
 		if err != nil {
-			return newError(err.Error())
+			return newError("%v", err)
 		}
 
 		for name, abi := range abis {
@@ -56,6 +70,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return nil
 
 	case *ast.OnStatement:
+		if node.Method == nil {
+			return evalOnHook(node, env)
+		}
+
 		params := node.Parameters
 		body := node.Body
 
@@ -88,13 +106,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			event.Address = &obj.Address
 		}
 
-		m, ok := event.ABI.Events[method]
-		if !ok {
-			return newError("Method not found for event")
+		m, err := ethereum.FindEvent(event.ABI, method, len(params))
+		if err != nil {
+			return newError("%v", err)
 		}
 
 		if len(m.Inputs) != len(params) {
-			return newError("Event len different %d and %d", len(m.Inputs), len(params))
+			return newError("event %s has %d parameters, found %d", m.Sig(), len(m.Inputs), len(params))
 		}
 
 		// Check if we listen for a specific address
@@ -102,48 +120,79 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			obj := Eval(node.Address, env)
 			addr, err := evalAddress(env, obj)
 			if err != nil {
-				return newError(err.Error())
+				return newError("%v", err)
 			}
 
 			i := addr.ToAddress()
 			event.Address = &i
 		}
 
-		objs := []object.Object{}
-		args := abi.Arguments{}
-
-		for indx, i := range m.Inputs {
-			if i.Indexed {
-				objs = append(objs, Eval(params[indx].Value, env))
-				args = append(args, i)
+		if node.Confirmations != nil {
+			obj := Eval(node.Confirmations, env)
+			if isError(obj) {
+				return obj
+			}
+			num, ok := obj.(*object.Integer)
+			if !ok || num.Value.Sign() < 0 || !num.Value.IsUint64() {
+				return newError("confirmations must be a positive integer, got %s", obj.Inspect())
 			}
+			event.Confirmations = num.Value.Uint64()
 		}
 
+		// Encode the indexed parameters with a value as topic filters.
+		// The first topic is always the event signature and is set by the manager
 		topics := []*web3.Hash{}
-		for indx, arg := range args {
-			if objs[indx] == nil {
+		for indx, i := range m.Inputs {
+			if !i.Indexed {
+				continue
+			}
+			if params[indx].Value == nil {
 				topics = append(topics, nil)
-			} else {
-				input, err := encoding.Decode(objs[indx], *arg.Type)
-				if err != nil {
-					panic(err)
-				}
-				topic, err := abi.EncodeTopic(arg.Type, input)
-				if err != nil {
-					panic(err)
-				}
-				topics = append(topics, &topic)
+				continue
+			}
+
+			obj := Eval(params[indx].Value, env)
+			if isError(obj) {
+				return obj
 			}
-		}
 
-		fmt.Println("-- topics --")
-		fmt.Println(topics)
+			input, err := encoding.Decode(obj, *i.Type)
+			if err != nil {
+				return newError("failed to decode topic %s: %v", params[indx].Identifier.Value, err)
+			}
+			topic, err := abi.EncodeTopic(i.Type, input)
+			if err != nil {
+				return newError("failed to encode topic %s: %v", params[indx].Identifier.Value, err)
+			}
+			topics = append(topics, &topic)
+		}
 
-		env.Set(fmt.Sprintf("%s_%s", contract, method), event)
+		// remove the trailing wildcards
+		for len(topics) > 0 && topics[len(topics)-1] == nil {
+			topics = topics[:len(topics)-1]
+		}
+		event.Topics = topics
+
+		// The id is the event and the order among the statements of the same
+		// event, i.e. ERC20.Transfer(address,address,uint256) and then
+		// ERC20.Transfer(address,address,uint256)#2. It is the key of the
+		// checkpoints, so it does not change when the script is edited
+		// around the statement.
+		event.ID = fmt.Sprintf("%s.%s", contract, m.Sig())
+		for n := 2; ; n++ {
+			if _, ok := env.Get(event.ID); !ok {
+				break
+			}
+			event.ID = fmt.Sprintf("%s.%s#%d", contract, m.Sig(), n)
+		}
+		env.Set(event.ID, event)
 		return nil
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: big.NewInt(node.Value)}
+		return &object.Integer{Value: new(big.Int).Set(node.Value)}
+
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: new(big.Rat).Set(node.Value)}
 
 	case *ast.BytesLiteral:
 		return &object.Bytes{Value: node.Value}
@@ -159,6 +208,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -177,6 +230,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -207,6 +272,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			env.Set(node.Name[indx].Value, val)
 		}
 
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
@@ -235,11 +303,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return ApplyFunction(env, function, args)
+		return addFrame(ApplyFunction(env, function, args), function, node)
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -249,6 +320,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return &object.Array{Elements: elements}
 
 	case *ast.IndexExpression:
+		if res, ok := evalExplicitSend(node, env); ok {
+			return res
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -262,8 +337,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
+
+		// explicit method of a contract, i.e. token["transfer(address,uint256)"]
+		if instance, ok := left.(*object.Instance); ok && index.Type() == object.STRING_OBJ {
+			return instanceMethod(instance, index.(*object.String).Value, env)
+		}
 		return evalIndexExpression(left, index)
 
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 
@@ -287,6 +370,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -310,7 +395,7 @@ func evalDotIndexExpression(env *object.Environment, left object.Object, index a
 				panic("Y")
 			}
 			args := evalExpressions(obj.Arguments, env)
-			return ApplyFunction(env, call, args)
+			return addFrame(ApplyFunction(env, call, args), call, obj)
 		}
 		return newError("Dot access to hash object requires an identifier")
 
@@ -364,9 +449,13 @@ func evalAccountCall(account *object.Account, expr ast.Expression, env *object.E
 		return newError("name not found")
 	}
 
+	if name.Value == "address" {
+		return &object.Address{Value: account.Addr.String()}
+	}
+
 	rpcEndpoint, err := env.GetRPCEndpoint()
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 
 	c, _ := jsonrpc.NewClient(rpcEndpoint)
@@ -375,14 +464,14 @@ func evalAccountCall(account *object.Account, expr ast.Expression, env *object.E
 	case "nonce":
 		nonce, err := c.Eth().GetNonce(account.Addr, web3.Latest)
 		if err != nil {
-			return newError(err.Error())
+			return newError("%v", err)
 		}
 		return &object.Integer{Value: big.NewInt(int64(nonce))}
 
 	case "balance":
 		balance, err := c.Eth().GetBalance(account.Addr, web3.Latest)
 		if err != nil {
-			return newError(err.Error())
+			return newError("%v", err)
 		}
 		return &object.Integer{Value: balance}
 
@@ -392,6 +481,15 @@ func evalAccountCall(account *object.Account, expr ast.Expression, env *object.E
 }
 
 func evalInstanceCall(instance *object.Instance, expr ast.Expression, env *object.Environment) object.Object {
+	// transaction, i.e. token.transfer(to, value).send()
+	if call, send, ok := sendCall(expr); ok {
+		name, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return newError("name not found")
+		}
+		return evalSendTransaction(instance, name.Value, call, send, env)
+	}
+
 	call, ok := expr.(*ast.CallExpression)
 	if !ok {
 		return newError("it is not a call")
@@ -407,21 +505,37 @@ func evalInstanceCall(instance *object.Instance, expr ast.Expression, env *objec
 		return args[0]
 	}
 
+	return callInstanceMethod(instance, name.Value, args, env)
+}
+
+// instanceMethod returns the method of the instance with the name or the
+// signature, i.e. token["transfer(address,uint256)"], as a function
+func instanceMethod(instance *object.Instance, name string, env *object.Environment) object.Object {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return callInstanceMethod(instance, name, args, env)
+		},
+	}
+}
+
+// callInstanceMethod calls the method of the contract with the name or the
+// signature that matches the arguments
+func callInstanceMethod(instance *object.Instance, name string, args []object.Object, env *object.Environment) object.Object {
+	method, err := ethereum.FindMethod(instance.ABI, name, args)
+	if err != nil {
+		return newError("%v", err)
+	}
+
 	rpcEndpoint, err := env.GetRPCEndpoint()
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 
 	client, _ := jsonrpc.NewClient(rpcEndpoint)
 
-	method, ok := instance.ABI.Methods[name.Value]
-	if !ok {
-		return newError(fmt.Sprintf("method %s not found", name.Value))
-	}
-
 	data, err := encoding.Pack(method.Inputs, args)
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 
 	msg := &web3.CallMsg{
@@ -431,17 +545,17 @@ func evalInstanceCall(instance *object.Instance, expr ast.Expression, env *objec
 
 	rawStr, err := client.Eth().Call(msg, web3.Latest)
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 
 	// Decode output
 	raw, err := hex.DecodeString(rawStr[2:])
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 	result, err := encoding.Unpack(method.Outputs, raw)
 	if err != nil {
-		return newError(err.Error())
+		return newError("%v", err)
 	}
 
 	if len(result) > 1 {
@@ -484,7 +598,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ || rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -526,6 +640,10 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if right.Type() == object.DECIMAL_OBJ {
+		value := right.(*object.Decimal).Value
+		return &object.Decimal{Value: new(big.Rat).Neg(value)}
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
@@ -538,10 +656,12 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.DECIMAL_OBJ && isNumber(right), isNumber(left) && right.Type() == object.DECIMAL_OBJ:
+		return evalDecimalInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
@@ -551,6 +671,32 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
+// maxShift is the largest shift count of << and >>, enough for any 256 bits value
+const maxShift = 1024
+
+// maxIntegerBits is the largest size of the result of ** and <<, well above
+// the 256 bits of the EVM. It stops a script from allocating without bound.
+const maxIntegerBits = 4096
+
+// evalLogicalExpression evaluates && and || and only evaluates the right side
+// if the left side does not decide the result
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
@@ -563,11 +709,91 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: big.NewInt(1).Mul(leftVal, rightVal)}
 	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: big.NewInt(1).Div(leftVal, rightVal)}
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: big.NewInt(1).Mod(leftVal, rightVal)}
+	case "**":
+		if rightVal.Sign() < 0 {
+			return newError("negative exponent: %s", rightVal.String())
+		}
+		// the result has at least (bits(left) - 1) * right + 1 bits, the
+		// bases 0, 1 and -1 are the only ones that do not grow
+		if bits := leftVal.BitLen() - 1; bits > 0 {
+			if !rightVal.IsUint64() || rightVal.Uint64() > maxIntegerBits/uint64(bits) {
+				return newError("result of ** exceeds %d bits", maxIntegerBits)
+			}
+		}
+		res := big.NewInt(1).Exp(leftVal, rightVal, nil)
+		if res.BitLen() > maxIntegerBits {
+			return newError("result of ** exceeds %d bits", maxIntegerBits)
+		}
+		return &object.Integer{Value: res}
+	case "&":
+		return &object.Integer{Value: big.NewInt(1).And(leftVal, rightVal)}
+	case "|":
+		return &object.Integer{Value: big.NewInt(1).Or(leftVal, rightVal)}
+	case "^":
+		return &object.Integer{Value: big.NewInt(1).Xor(leftVal, rightVal)}
+	case "<<", ">>":
+		if rightVal.Sign() < 0 || !rightVal.IsUint64() || rightVal.Uint64() > maxShift {
+			return newError("invalid shift count: %s", rightVal.String())
+		}
+		if operator == "<<" {
+			if leftVal.BitLen()+int(rightVal.Uint64()) > maxIntegerBits {
+				return newError("result of << exceeds %d bits", maxIntegerBits)
+			}
+			return &object.Integer{Value: big.NewInt(1).Lsh(leftVal, uint(rightVal.Uint64()))}
+		}
+		return &object.Integer{Value: big.NewInt(1).Rsh(leftVal, uint(rightVal.Uint64()))}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalDecimalInfixExpression evaluates an operation between two numbers
+// where at least one of them is a decimal, the result is a decimal
+func evalDecimalInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toRat(left)
+	rightVal := toRat(right)
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Value: new(big.Rat).Add(leftVal, rightVal)}
+	case "-":
+		return &object.Decimal{Value: new(big.Rat).Sub(leftVal, rightVal)}
+	case "*":
+		return &object.Decimal{Value: new(big.Rat).Mul(leftVal, rightVal)}
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return &object.Decimal{Value: new(big.Rat).Quo(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
 	case "!=":
@@ -577,6 +803,21 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.DECIMAL_OBJ
+}
+
+// toRat returns the value of an integer or a decimal as a rational
+func toRat(obj object.Object) *big.Rat {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return new(big.Rat).SetInt(obj.Value)
+	case *object.Decimal:
+		return obj.Value
+	}
+	return nil
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 
@@ -593,19 +834,255 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 	}
 }
 
+// evalLoopBody runs one iteration of a loop. It returns true if the loop
+// has to stop and the result to return from the loop statement, if any
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (bool, object.Object) {
+	result := Eval(body, env)
+	if result == nil {
+		return false, nil
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return true, nil
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return true, result
+	}
+	return false, nil
+}
+
+// evalForStatement runs every iteration in its own scope with the loop
+// variables, the outer variables are updated with assignments
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	if node.End != nil {
+		return evalRangeLoop(node, iterable, env)
+	}
+
+	switch obj := iterable.(type) {
+	case *object.Array:
+		// copy the elements, the body may modify the array
+		elements := append([]object.Object{}, obj.Elements...)
+		for indx, elem := range elements {
+			loopEnv := object.NewEnclosedEnvironment(env)
+			if node.Key != nil {
+				loopEnv.Set(node.Key.Value, &object.Integer{Value: big.NewInt(int64(indx))})
+			}
+			loopEnv.Set(node.Value.Value, elem)
+
+			if stop, result := evalLoopBody(node.Body, loopEnv); stop {
+				return result
+			}
+		}
+
+	case *object.Hash:
+		for _, pair := range sortedPairs(obj) {
+			loopEnv := object.NewEnclosedEnvironment(env)
+			if node.Key != nil {
+				loopEnv.Set(node.Key.Value, pair.Key)
+				loopEnv.Set(node.Value.Value, pair.Value)
+			} else {
+				loopEnv.Set(node.Value.Value, pair.Key)
+			}
+
+			if stop, result := evalLoopBody(node.Body, loopEnv); stop {
+				return result
+			}
+		}
+
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
+
+	return nil
+}
+
+// evalRangeLoop iterates from the start of the range up to the end, not included
+func evalRangeLoop(node *ast.ForStatement, start object.Object, env *object.Environment) object.Object {
+	end := Eval(node.End, env)
+	if isError(end) {
+		return end
+	}
+
+	from, ok := start.(*object.Integer)
+	if !ok {
+		return newError("range start must be an integer, got %s", start.Type())
+	}
+	to, ok := end.(*object.Integer)
+	if !ok {
+		return newError("range end must be an integer, got %s", end.Type())
+	}
+
+	for i := new(big.Int).Set(from.Value); i.Cmp(to.Value) < 0; i.Add(i, big.NewInt(1)) {
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Value.Value, &object.Integer{Value: new(big.Int).Set(i)})
+
+		if stop, result := evalLoopBody(node.Body, loopEnv); stop {
+			return result
+		}
+	}
+	return nil
+}
+
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if stop, result := evalLoopBody(node.Body, object.NewEnclosedEnvironment(env)); stop {
+			return result
+		}
+	}
+}
+
+// sortedPairs returns the pairs of the hash sorted by key so
+// that the iteration order is stable
+func sortedPairs(hash *object.Hash) []object.HashPair {
+	pairs := []object.HashPair{}
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i].Key, pairs[j].Key
+		if a.Type() != b.Type() {
+			return a.Type() < b.Type()
+		}
+		if x, ok := a.(*object.Integer); ok {
+			return x.Value.Cmp(b.(*object.Integer).Value) < 0
+		}
+		return a.Inspect() < b.Inspect()
+	})
+	return pairs
+}
+
+// evalAssignStatement updates the nearest binding of an identifier or
+// an element of an array or a hash. Compound operators (i.e. +=) apply
+// the operator to the current value first.
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if node.Operator != "=" {
+			current := evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+			if val = evalCompoundAssign(node.Operator, current, val); isError(val) {
+				return val
+			}
+		}
+		if !env.Assign(target.Value, val) {
+			return newError("identifier not found: %s", target.Value)
+		}
+		return nil
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		var index object.Object
+		if target.Token.Type == token.DOT {
+			index = &object.String{Value: target.Index.(*ast.Identifier).Value}
+		} else if index = Eval(target.Index, env); isError(index) {
+			return index
+		}
+
+		if node.Operator != "=" {
+			current := evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			if val = evalCompoundAssign(node.Operator, current, val); isError(val) {
+				return val
+			}
+		}
+		return evalIndexAssign(left, index, val)
+	}
+
+	return newError("cannot assign to %s", node.Target.String())
+}
+
+// evalCompoundAssign applies the operator of a compound assignment, i.e. + for +=
+func evalCompoundAssign(operator string, current, val object.Object) object.Object {
+	return evalInfixExpression(strings.TrimSuffix(operator, "="), current, val)
+}
+
+func evalIndexAssign(left, index, val object.Object) object.Object {
+	switch obj := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be an integer, got %s", index.Type())
+		}
+		if !i.Value.IsInt64() || i.Value.Int64() < 0 || i.Value.Int64() >= int64(len(obj.Elements)) {
+			return newError("array index out of range: %s", i.Inspect())
+		}
+		obj.Elements[i.Value.Int64()] = val
+		return nil
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		if obj.Pairs == nil {
+			obj.Pairs = map[object.HashKey]object.HashPair{}
+		}
+		obj.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+		return nil
+	}
+
+	return newError("index assignment not supported: %s", left.Type())
+}
+
+// evalInterpolatedString joins the text of the string with the embedded
+// expressions as they are shown by Inspect
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		val := Eval(part, env)
+		if isError(val) {
+			return val
+		}
+		if val == nil {
+			val = NULL
+		}
+		out.WriteString(val.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := env.Builtins[node.Value]; ok {
+	if builtin, ok := env.GetBuiltin(node.Value); ok {
 		return builtin
 	}
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError("identifier not found: %s", node.Value)
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -647,6 +1124,89 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+
+	if idx.Sign() < 0 || idx.Cmp(big.NewInt(int64(len(value)))) >= 0 {
+		return NULL
+	}
+
+	i := idx.Int64()
+	return &object.String{Value: value[i : i+1]}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	// a missing bound is left as nil
+	var bounds [2]object.Object
+	for i, exp := range []ast.Expression{node.Start, node.End} {
+		if exp == nil {
+			continue
+		}
+		bounds[i] = Eval(exp, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	switch obj := left.(type) {
+	case *object.String:
+		start, end, err := sliceRange(bounds[0], bounds[1], len(obj.Value))
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: obj.Value[start:end]}
+
+	case *object.Array:
+		start, end, err := sliceRange(bounds[0], bounds[1], len(obj.Elements))
+		if err != nil {
+			return err
+		}
+		// copy the elements, assigning to the slice does not modify the array
+		elements := make([]object.Object, end-start)
+		copy(elements, obj.Elements[start:end])
+		return &object.Array{Elements: elements}
+	}
+
+	return newError("slice operator not supported: %s", left.Type())
+}
+
+// sliceRange returns the range of a slice over a value of the given length.
+// A nil start is 0 and a nil end is the length.
+func sliceRange(startObj, endObj object.Object, length int) (int, int, *object.Error) {
+	bound := func(obj object.Object, def int) (int, *object.Error) {
+		if obj == nil {
+			return def, nil
+		}
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return 0, newError("slice index must be an integer, got %s", obj.Type())
+		}
+		if i.Value.Sign() < 0 || i.Value.Cmp(big.NewInt(int64(length))) > 0 {
+			return 0, newError("slice index %s out of range with length %d", i.Value, length)
+		}
+		return int(i.Value.Int64()), nil
+	}
+
+	start, err := bound(startObj, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := bound(endObj, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	if start > end {
+		return 0, 0, newError("invalid slice indices: %d > %d", start, end)
+	}
+	return start, end, nil
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObj := hash.(*object.Hash)
 	k, ok := index.(object.Hashable)
@@ -729,6 +1289,7 @@ func encodeThisObject(log *web3.Log, event object.Event) object.Object {
 	encodePair("blocknumber", &object.Integer{Value: big.NewInt(int64(log.BlockNumber))})
 	encodePair("blockhash", &object.String{Value: hex.EncodeToString(log.BlockHash[:])})
 	encodePair("txhash", &object.String{Value: hex.EncodeToString(log.TransactionHash[:])})
+	encodePair("removed", nativeBoolToBooleanObject(log.Removed))
 	encodePair("obj", &object.Instance{
 		Name:    "", // dont need the name here
 		Address: log.Address,
@@ -738,13 +1299,39 @@ func encodeThisObject(log *web3.Log, event object.Event) object.Object {
 	return &object.Hash{Pairs: pairs}
 }
 
-// ApplyEvent runs the event. TODO. support this object
+// addFrame adds the call to the stack trace of an error raised in the body
+// of a function
+func addFrame(result, fn object.Object, call *ast.CallExpression) object.Object {
+	err, ok := result.(*object.Error)
+	if !ok || !err.Pos.IsValid() {
+		return result
+	}
+	if _, ok := fn.(*object.Function); !ok {
+		return result
+	}
+
+	name := "fn"
+	switch obj := call.Function.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+		name = obj.String()
+	case *ast.FunctionLiteral:
+		if obj.Name != nil {
+			name = obj.Name.Value
+		}
+	}
+
+	err.Stack = append(err.Stack, object.Frame{Name: name, Pos: call.Pos()})
+	return err
+}
+
+// ApplyEvent runs the event. An error object returned by the body or
+// a panic while evaluating it are returned as an error
 func ApplyEvent(event object.Event, args []object.Object, log *web3.Log) (object.Object, error) {
 	// extend env with args
 	env := object.NewEnclosedEnvironment(event.Env)
 
 	if len(event.Parameters) != len(args) {
-		return nil, fmt.Errorf("event parameters dont match: %d and %d", len(event.Parameters), args)
+		return nil, fmt.Errorf("event parameters dont match: %d and %d", len(event.Parameters), len(args))
 	}
 
 	for i, param := range event.Parameters {
@@ -753,18 +1340,56 @@ func ApplyEvent(event object.Event, args []object.Object, log *web3.Log) (object
 
 	env.Set("this", encodeThisObject(log, event))
 
-	// eval
-	evaluated := Eval(event.Body, env)
+	return applyBody("on "+event.Contract+"."+event.Method, event.Body, env)
+}
+
+// ApplyHook runs a lifecycle hook of the script
+func ApplyHook(hook *object.Hook) (object.Object, error) {
+	return applyBody("on "+hook.Name, hook.Body, object.NewEnclosedEnvironment(hook.Env))
+}
+
+// applyBody evaluates the body of an event or a hook. The error includes
+// the stack trace that ends in the handler with the given name.
+func applyBody(name string, body *ast.BlockStatement, env *object.Environment) (res object.Object, err error) {
+	// a panic in the body fails the call instead of the whole process
+	defer func() {
+		if r := recover(); r != nil {
+			res, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	evaluated := unwrapReturnValue(Eval(body, env))
+	if errObj, ok := evaluated.(*object.Error); ok {
+		errObj.Stack = append(errObj.Stack, object.Frame{Name: name, Pos: body.Pos()})
+		return evaluated, fmt.Errorf("%s", errObj.StackTrace())
+	}
 	return evaluated, nil
 }
 
+func evalOnHook(node *ast.OnStatement, env *object.Environment) object.Object {
+	name := node.Contract.Value
+	if name != "shutdown" {
+		return newError("unknown hook %s", name)
+	}
+	if env.GetHook(name) != nil {
+		return newError("hook %s already defined", name)
+	}
+
+	env.Set("on_"+name, &object.Hook{
+		Name: name,
+		Body: node.Body,
+		Env:  env,
+	})
+	return nil
+}
+
 // ApplyFunction applies a function. NOTE: The env is on the fn object
 func ApplyFunction(env *object.Environment, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
 		extendedEnv, err := extendFunctionEnv(fn, args)
 		if err != nil {
-			return newError(err.Error())
+			return newError("%v", err)
 		}
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
@@ -780,7 +1405,7 @@ func ApplyFunction(env *object.Environment, fn object.Object, args []object.Obje
 
 		address, err := evalAddress(env, args[0])
 		if err != nil {
-			return newError(err.Error())
+			return newError("%v", err)
 		}
 
 		return &object.Instance{
@@ -2,8 +2,12 @@ package evaluator
 
 import (
 	"math/big"
+	"reflect"
+	"sort"
 	"testing"
 
+	"github.com/umbracle/go-web3"
+	"github.com/umbracle/heura/heura/ethereum"
 	"github.com/umbracle/heura/heura/lexer"
 	"github.com/umbracle/heura/heura/object"
 	"github.com/umbracle/heura/heura/parser"
@@ -29,6 +33,17 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", 2},
+		{"10 ** 18", 1000000000000000000},
+		{"2 ** 3 ** 2", 512},
+		{"2 * 3 ** 2", 18},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 10", 1024},
+		{"1024 >> 3", 128},
+		{"(5 >> 2) & 1", 1},
 	}
 
 	for _, tt := range tests {
@@ -61,6 +76,29 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"let f = fn() { return true; }; 1 == 1 && f()", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{"0x0102 == 0x0102", true},
+		{"0x0102 != 0x0103", true},
+		{"Account(0x1111111111111111111111111111111111111111).address() == 0x1111111111111111111111111111111111111111", true},
+		{"0x1111111111111111111111111111111111111111 == Account(0x1111111111111111111111111111111111111111).address()", true},
+		{"Account(0x1111111111111111111111111111111111111111).address() == 0x2222222222222222222222222222222222222222", false},
+		{"Account(0x1111111111111111111111111111111111111111).address() != 0x0102", true},
+		{`1 == "1"`, false},
 	}
 
 	for _, tt := range tests {
@@ -141,6 +179,181 @@ if (10 > 1) {
 	}
 }
 
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let s = 0; for x in [1, 2, 3] { s += x; } s", 6},
+		{"let s = 0; for i, x in [5, 6, 7] { s += i * x; } s", 20},
+		{`let s = ""; for k in {"b": 1, "a": 2} { s += k; } s`, "ab"},
+		{`let s = 0; for k, v in {"b": 1, "a": 2} { s = s * 10 + v; } s`, 21},
+		{"let s = 0; for i in 0..5 { s += i; } s", 10},
+		{"let s = 0; for i in 5..0 { s += i; } s", 0},
+		{"let i = 0; while i < 5 { i += 1; } i", 5},
+		{"let s = 0; for i in 0..10 { if (i == 3) { break; } s += i; } s", 3},
+		{"let s = 0; for i in 0..5 { if (i == 3) { continue; } s += i; } s", 7},
+		{"let s = 0; for i in 0..3 { for j in 0..3 { if (j == 1) { break; } s += 1; } } s", 3},
+		{"fn f() { for i in 0..10 { if (i == 4) { return i; } } return 0; } f()", 4},
+		{"let i = 10; for i in 0..3 {} i", 10},
+		{"let x = 1; for i, x in [5, 6] {} x", 1},
+		{"let k = 1; for k, v in {2: 3} {} k", 1},
+		{"for i in 0..3 { let y = i; } y", "identifier not found: y"},
+		{"let i = 0; while i < 3 { let y = i; i += 1; } y", "identifier not found: y"},
+		{"let fs = []; for i in 0..3 { fs = push(fs, fn() { i }); } fs[0]() + fs[2]()", 2},
+		{"for x in 1 {}", "cannot iterate over INTEGER"},
+		{`for i in 0.."a" {}`, "range end must be an integer, got STRING"},
+		{"for x in [1] { x + true; }", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("expected %q but got %q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("expected error %q but got %q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object %T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestAssignStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 5; x -= 2; x", 3},
+		{"let x = 5; x *= 2; x", 10},
+		{"let x = 10; x /= 2; x", 5},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let s = 0; for x in [1, 2, 3] { s += x; } s", 6},
+		{"let i = 0; while i < 5 { i += 1; } i", 5},
+		{"let c = 0; let inc = fn() { c += 1; }; inc(); inc(); c", 2},
+		{"let c = 0; let f = fn() { let c = 5; c = 6; }; f(); c", 0},
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; a[2] *= 3; a[2]", 9},
+		{`let h = {"a": 1}; h["a"] += 1; h["a"]`, 2},
+		{`let h = {}; h["b"] = 3; h["b"]`, 3},
+		{`let h = {"a": 1}; h.a = 7; h.a`, 7},
+		{`let h = {"a": {"b": 1}}; h["a"]["b"] = 2; h["a"]["b"]`, 2},
+		{`let h = {"a": {"b": 1}}; h.a.b = 7; h.a.b`, 7},
+		{`let h = {"a": {"b": 1}}; h.a["b"] += 2; h["a"]["b"]`, 3},
+		{`let h = {"a": {"b": {"c": 1}}}; h.a.b.c = 4; h.a.b.c`, 4},
+		{`let h = {"a": {}}; h["a"].b = 5; h.a.b`, 5},
+		{`let h = {"a": [1, 2]}; h.a[1] = 6; h["a"][1]`, 6},
+		{"x = 1;", "identifier not found: x"},
+		{"let x = 1; x += true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2;", "array index out of range: 1"},
+		{`let a = [1]; a["x"] = 2;`, "array index must be an integer, got STRING"},
+		{`let h = {}; h[fn() {}] = 2;`, "unusable as hash key: FUNCTION"},
+		{`let s = "a"; s[0] = "b";`, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("expected %q but got %q", expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("expected error %q but got %q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object %T (%+v)", evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	tests := []struct {
+		input    string
+		typ      object.ObjectType
+		expected string
+	}{
+		{"1.5", object.DECIMAL_OBJ, "1.5"},
+		{"-1.25", object.DECIMAL_OBJ, "-1.25"},
+		{"0.1 + 0.2", object.DECIMAL_OBJ, "0.3"},
+		{"1.5 + 1", object.DECIMAL_OBJ, "2.5"},
+		{"2 * 1.5", object.DECIMAL_OBJ, "3"},
+		{"1 - 1.5", object.DECIMAL_OBJ, "-0.5"},
+		{"1.0 / 3", object.DECIMAL_OBJ, "0.333333333333333333"},
+		{"2 / 3.0", object.DECIMAL_OBJ, "0.666666666666666667"},
+		{"10 / 4", object.INTEGER_OBJ, "2"},
+		{"1.5 > 1", object.BOOLEAN_OBJ, "true"},
+		{"1 >= 1.0", object.BOOLEAN_OBJ, "true"},
+		{"1.0 == 1", object.BOOLEAN_OBJ, "true"},
+		{"0.5 != 0.50", object.BOOLEAN_OBJ, "false"},
+		{"let x = 1; x += 0.5; x", object.DECIMAL_OBJ, "1.5"},
+		{"for i in 0..3 { }", object.NULL_OBJ, ""},
+		{"formatUnits(1500000000000000000, 18)", object.DECIMAL_OBJ, "1.5"},
+		{"formatUnits(1234, 2) * 2", object.DECIMAL_OBJ, "24.68"},
+		{"formatUnits(5, 0)", object.DECIMAL_OBJ, "5"},
+		{`parseUnits("1.5", 18)`, object.INTEGER_OBJ, "1500000000000000000"},
+		{"parseUnits(2.25, 2)", object.INTEGER_OBJ, "225"},
+		{"parseUnits(3, 6)", object.INTEGER_OBJ, "3000000"},
+		{"ether(1.5)", object.INTEGER_OBJ, "1500000000000000000"},
+		{"gwei(2)", object.INTEGER_OBJ, "2000000000"},
+		{"1000000000000000000000 + 1", object.INTEGER_OBJ, "1000000000000000000001"},
+		{"1_000 * 1e18", object.INTEGER_OBJ, "1000000000000000000000"},
+		{"2 gwei + 1", object.INTEGER_OBJ, "2000000001"},
+		{"formatUnits(1.5 ether, 18)", object.DECIMAL_OBJ, "1.5"},
+		{"1.5e2", object.DECIMAL_OBJ, "150"},
+		{"1.5 / 0", object.ERROR_OBJ, "division by zero"},
+		{"1.5 % 1", object.ERROR_OBJ, "unknown operator: DECIMAL % INTEGER"},
+		{`1.5 + "a"`, object.ERROR_OBJ, "type mismatch: DECIMAL + STRING"},
+		{`parseUnits("1.234", 2)`, object.ERROR_OBJ, "1.234 has more than 2 decimals"},
+		{`parseUnits("abc", 2)`, object.ERROR_OBJ, `could not parse "abc" as a number`},
+		{"formatUnits(1, 256)", object.ERROR_OBJ, "decimals must be between 0 and 255, got 256"},
+		{"formatUnits(1.5, 2)", object.ERROR_OBJ, "argument to `formatUnits` must be INTEGER, got DECIMAL"},
+		{"gwei(0.0000000001)", object.ERROR_OBJ, "0.0000000001 has more than 9 decimals"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			evaluated = NULL
+		}
+
+		if evaluated.Type() != tt.typ {
+			t.Errorf("%s: expected %s but got %s (%s)", tt.input, tt.typ, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if tt.typ == object.NULL_OBJ {
+			continue
+		}
+
+		result := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			result = errObj.Message
+		}
+		if result != tt.expected {
+			t.Errorf("%s: expected %q but got %q", tt.input, tt.expected, result)
+		}
+	}
+}
+
 func TestErrorHandling(t *testing.T) {
 	tests := []struct {
 		input           string
@@ -170,6 +383,42 @@ func TestErrorHandling(t *testing.T) {
 			"if (10 > 1) { true + false; }",
 			"unknown operator: BOOLEAN + BOOLEAN",
 		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"1 % 0",
+			"division by zero",
+		},
+		{
+			"2 ** -1",
+			"negative exponent: -1",
+		},
+		{
+			"1 << -1",
+			"invalid shift count: -1",
+		},
+		{
+			"2 ** 100000000000",
+			"result of ** exceeds 4096 bits",
+		},
+		{
+			"3 ** 4096",
+			"result of ** exceeds 4096 bits",
+		},
+		{
+			"(1 << 1024) << 1024 << 1024 << 1024",
+			"result of << exceeds 4096 bits",
+		},
+		{
+			"true && 1 + true",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`"a" <= "b"`,
+			"unknown operator: STRING <= STRING",
+		},
 		{
 			`
 if (10 > 1) {
@@ -285,6 +534,38 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let value = 5; "transfer of ${value}"`, "transfer of 5"},
+		{`let a = "x"; let b = [1, 2]; "${a}-${b}-${1.5}-${true}"`, "x-[1, 2]-1.5-true"},
+		{`"sum: ${1 + 2 * 3}!"`, "sum: 7!"},
+		{`let h = {"from": "alice"}; "from ${h["from"]}"`, "from alice"},
+		{`let f = fn(x) { "<${x}>" }; "${f("y")}"`, "<y>"},
+		{`"line\n\ttab"`, "line\n\ttab"},
+		{"`multi\nline`", "multi\nline"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"value ${missing}"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "identifier not found: missing" {
+		t.Errorf("expected identifier not found error. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -368,6 +649,163 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestStringFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		typ      object.ObjectType
+		expected string
+	}{
+		{`format("%s has %d tokens", "alice", 10)`, object.STRING_OBJ, "alice has 10 tokens"},
+		{`format("%x %t", 255, true)`, object.STRING_OBJ, "ff true"},
+		{`format("%d", 1000000000000000000000)`, object.STRING_OBJ, "1000000000000000000000"},
+		{`format("%.2f|%s|%6s", 1.005, 1.5, 2.5)`, object.STRING_OBJ, "1.01|1.5|   2.5"},
+		{`format("%s", 0x01)`, object.STRING_OBJ, "0x01"},
+		{`split("a,b,c", ",")[1]`, object.STRING_OBJ, "b"},
+		{`len(split("a,b,c", ","))`, object.INTEGER_OBJ, "3"},
+		{`join(["a", 1, true], "-")`, object.STRING_OBJ, "a-1-true"},
+		{`join(split("a b", " "), ",")`, object.STRING_OBJ, "a,b"},
+		{`contains("transfer", "fer")`, object.BOOLEAN_OBJ, "true"},
+		{`contains("transfer", "x")`, object.BOOLEAN_OBJ, "false"},
+		{`startsWith("0xabc", "0x")`, object.BOOLEAN_OBJ, "true"},
+		{`endsWith("token.eth", ".eth")`, object.BOOLEAN_OBJ, "true"},
+		{`replace("a-b-c", "-", "+")`, object.STRING_OBJ, "a+b+c"},
+		{`trim("  a b \n")`, object.STRING_OBJ, "a b"},
+		{`trim("xxaxx", "x")`, object.STRING_OBJ, "a"},
+		{`upper("Dai")`, object.STRING_OBJ, "DAI"},
+		{`lower("DAI")`, object.STRING_OBJ, "dai"},
+		{`substring("heura", 1, 3)`, object.STRING_OBJ, "eu"},
+		{`substring("heura", 2)`, object.STRING_OBJ, "ura"},
+		{`"heura"[1:4]`, object.STRING_OBJ, "eur"},
+		{`"heura"[:2]`, object.STRING_OBJ, "he"},
+		{`"heura"[3:]`, object.STRING_OBJ, "ra"},
+		{`let s = "heura"; s[0:len(s)]`, object.STRING_OBJ, "heura"},
+		{`"heura"[1]`, object.STRING_OBJ, "e"},
+		{`"heura"[5]`, object.NULL_OBJ, ""},
+		{`toInt("42")`, object.INTEGER_OBJ, "42"},
+		{`toInt("-0x10")`, object.INTEGER_OBJ, "-16"},
+		{`toInt(" 1000000000000000000000 ")`, object.INTEGER_OBJ, "1000000000000000000000"},
+		{`toInt(0x0100)`, object.INTEGER_OBJ, "256"},
+		{`toInt(0x)`, object.INTEGER_OBJ, "0"},
+		{`toInt(-2.5)`, object.INTEGER_OBJ, "-2"},
+		{`toString(42)`, object.STRING_OBJ, "42"},
+		{`toString(255, 16)`, object.STRING_OBJ, "ff"},
+		{`toString(1.5)`, object.STRING_OBJ, "1.5"},
+		{`toString(0xab)`, object.STRING_OBJ, "0xab"},
+		{`toString(toInt("7")) + "!"`, object.STRING_OBJ, "7!"},
+		{`"heura"[2:1]`, object.ERROR_OBJ, "invalid slice indices: 2 > 1"},
+		{`"heura"[1:6]`, object.ERROR_OBJ, "slice index 6 out of range with length 5"},
+		{`"heura"["a":]`, object.ERROR_OBJ, "slice index must be an integer, got STRING"},
+		{`1[0:1]`, object.ERROR_OBJ, "slice operator not supported: INTEGER"},
+		{`format(1)`, object.ERROR_OBJ, "argument to `format` must be STRING, got INTEGER"},
+		{`split("a")`, object.ERROR_OBJ, "wrong number of arguments. got=1, want=2"},
+		{`contains("a", 1)`, object.ERROR_OBJ, "argument to `contains` must be STRING, got INTEGER"},
+		{`trim()`, object.ERROR_OBJ, "wrong number of arguments. got=0, want=1 or 2"},
+		{`join("a", ",")`, object.ERROR_OBJ, "argument to `join` must be ARRAY, got STRING"},
+		{`toInt("0x")`, object.ERROR_OBJ, `could not parse "0x" as integer`},
+		{`toInt("1.5")`, object.ERROR_OBJ, `could not parse "1.5" as integer`},
+		{`toInt(true)`, object.ERROR_OBJ, "argument to `toInt` not supported, got BOOLEAN"},
+		{`toString(1, 1)`, object.ERROR_OBJ, "base must be between 2 and 62, got 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			evaluated = NULL
+		}
+
+		if evaluated.Type() != tt.typ {
+			t.Errorf("%s: expected %s but got %s (%s)", tt.input, tt.typ, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if tt.typ == object.NULL_OBJ {
+			continue
+		}
+
+		result := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			result = errObj.Message
+		}
+		if result != tt.expected {
+			t.Errorf("%s: expected %q but got %q", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestCollectionFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		typ      object.ObjectType
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", object.ARRAY_OBJ, "[2, 4, 6]"},
+		{"map([5, 5], fn(x, i) { x + i })", object.ARRAY_OBJ, "[5, 6]"},
+		{"map([], fn(x) { x })", object.ARRAY_OBJ, "[]"},
+		{`map([1, 2], toString)`, object.ARRAY_OBJ, "[1, 2]"},
+		{"fn pair() { return 1, 2 }; map(pair(), fn(x) { x * 10 })", object.ARRAY_OBJ, "[10, 20]"},
+		{"filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })", object.ARRAY_OBJ, "[2, 4]"},
+		{"filter([1, 2, 3], fn(x, i) { i > 0 })", object.ARRAY_OBJ, "[2, 3]"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x })", object.INTEGER_OBJ, "6"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", object.INTEGER_OBJ, "16"},
+		{"reduce([], fn(acc, x) { acc + x }, 0)", object.INTEGER_OBJ, "0"},
+		{"sort([3, 1.5, 2])", object.ARRAY_OBJ, "[1.5, 2, 3]"},
+		{`sort(["b", "c", "a"])`, object.ARRAY_OBJ, "[a, b, c]"},
+		{"sort([1, 3, 2], fn(a, b) { a > b })", object.ARRAY_OBJ, "[3, 2, 1]"},
+		{"let a = [2, 1]; sort(a); a", object.ARRAY_OBJ, "[2, 1]"},
+		{`let ts = [{"value": 1}, {"value": 3}, {"value": 2}]; map(sort(filter(ts, fn(t) { t.value > 1 }), fn(a, b) { a.value > b.value }), fn(t) { t.value })`, object.ARRAY_OBJ, "[3, 2]"},
+		{`let ts = [{"value": 1}, {"value": 3}]; reduce(ts, fn(acc, t) { acc + t.value }, 0)`, object.INTEGER_OBJ, "4"},
+		{"contains([1, 2, 3], 2)", object.BOOLEAN_OBJ, "true"},
+		{`contains(["a", "b"], "b")`, object.BOOLEAN_OBJ, "true"},
+		{"contains([1, 2, 3], 4)", object.BOOLEAN_OBJ, "false"},
+		{`contains({"a": 1}, "a")`, object.BOOLEAN_OBJ, "true"},
+		{`contains({"a": 1}, "b")`, object.BOOLEAN_OBJ, "false"},
+		{`keys({"b": 2, "a": 1})`, object.ARRAY_OBJ, "[a, b]"},
+		{`values({"b": 2, "a": 1})`, object.ARRAY_OBJ, "[1, 2]"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a")`, object.INTEGER_OBJ, "1"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); keys(h)`, object.ARRAY_OBJ, "[b]"},
+		{`delete({"a": 1}, "c")`, object.NULL_OBJ, ""},
+		{`let h = merge({"a": 1, "b": 2}, {"b": 3}); values(h)`, object.ARRAY_OBJ, "[1, 3]"},
+		{`let a = {"a": 1}; merge(a, {"b": 2}); keys(a)`, object.ARRAY_OBJ, "[a]"},
+		{"[1, 2, 3, 4][1:3]", object.ARRAY_OBJ, "[2, 3]"},
+		{"[1, 2, 3][:1]", object.ARRAY_OBJ, "[1]"},
+		{"[1, 2, 3][3:]", object.ARRAY_OBJ, "[]"},
+		{"let a = [1, 2, 3]; let b = a[0:2]; b[0] = 5; a", object.ARRAY_OBJ, "[1, 2, 3]"},
+		{"[1, 2][0:3]", object.ERROR_OBJ, "slice index 3 out of range with length 2"},
+		{"map(1, fn(x) { x })", object.ERROR_OBJ, "argument to `map` must be ARRAY, got INTEGER"},
+		{"map([1], 1)", object.ERROR_OBJ, "argument to `map` must be FUNCTION, got INTEGER"},
+		{"map([1, true], fn(x) { x + 1 })", object.ERROR_OBJ, "type mismatch: BOOLEAN + INTEGER"},
+		{"reduce([], fn(acc, x) { acc + x })", object.ERROR_OBJ, "reduce of empty array with no initial value"},
+		{`sort([1, "a"])`, object.ERROR_OBJ, "cannot compare STRING and INTEGER"},
+		{"sort([1, 2], fn(a, b) { 1 })", object.ERROR_OBJ, "comparator of `sort` must return BOOLEAN, got INTEGER"},
+		{"contains(1, 1)", object.ERROR_OBJ, "argument to `contains` not supported, got INTEGER"},
+		{`keys([1])`, object.ERROR_OBJ, "argument to `keys` must be HASH, got ARRAY"},
+		{`delete({}, [1])`, object.ERROR_OBJ, "unusable as hash key: ARRAY"},
+		{`merge({}, 1)`, object.ERROR_OBJ, "argument to `merge` must be HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			evaluated = NULL
+		}
+
+		if evaluated.Type() != tt.typ {
+			t.Errorf("%s: expected %s but got %s (%s)", tt.input, tt.typ, evaluated.Type(), evaluated.Inspect())
+			continue
+		}
+		if tt.typ == object.NULL_OBJ {
+			continue
+		}
+
+		result := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			result = errObj.Message
+		}
+		if result != tt.expected {
+			t.Errorf("%s: expected %q but got %q", tt.input, tt.expected, result)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -530,6 +968,254 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestOnStatementFilters(t *testing.T) {
+	input := `
+artifact ("ERC20")
+
+let TO = 0x2222222222222222222222222222222222222222
+
+on ERC20(0x1111111111111111111111111111111111111111).Transfer(from, to=TO, value) confirmations 12 {}
+`
+	env := object.NewEnvironment()
+	if res := Eval(parser.New(lexer.New(input)).ParseProgram(), env); isError(res) {
+		t.Fatal(res.Inspect())
+	}
+
+	events := env.GetOnStatements()
+	if len(events) != 1 {
+		t.Fatalf("expected one event but found %d", len(events))
+	}
+	event := events[0]
+
+	if event.Address == nil || *event.Address != web3.HexToAddress("0x1111111111111111111111111111111111111111") {
+		t.Fatalf("bad address %v", event.Address)
+	}
+
+	to := web3.HexToAddress("0x2222222222222222222222222222222222222222")
+	expected := web3.Hash{}
+	copy(expected[12:], to[:])
+
+	if len(event.Topics) != 2 {
+		t.Fatalf("expected two topics but found %d", len(event.Topics))
+	}
+	if event.Topics[0] != nil {
+		t.Fatal("expected the first topic to be a wildcard")
+	}
+	if event.Topics[1] == nil || *event.Topics[1] != expected {
+		t.Fatalf("bad topic %v", event.Topics[1])
+	}
+	if event.Confirmations != 12 {
+		t.Fatalf("expected 12 confirmations but found %d", event.Confirmations)
+	}
+}
+
+func TestOverloads(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`on ERC20["Transfer(address, address, uint256)"](from, to, value) {}`, ""},
+		{`on ERC20["Transfer(address)"](from) {}`, "event Transfer(address) not found"},
+		{`on ERC20.Transfer(from, to) {}`, "event Transfer(address,address,uint256) has 3 parameters, found 2"},
+		{`let t = ERC20(0x1111111111111111111111111111111111111111); t["transfer(address,uint256)"]`, ""},
+		{`let t = ERC20(0x1111111111111111111111111111111111111111); t.other()`, "method other not found"},
+		{`let t = ERC20(0x1111111111111111111111111111111111111111); t.transfer(1)`, "method transfer(address,uint256) expects 2 arguments, found 1"},
+		{`let t = ERC20(0x1111111111111111111111111111111111111111); t["transfer(uint256)"](1)`, "method transfer(uint256) not found"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		res := Eval(parser.New(lexer.New("artifact (\"ERC20\")\n"+tt.input)).ParseProgram(), env)
+
+		if tt.expected == "" {
+			if isError(res) {
+				t.Errorf("%s: unexpected error %s", tt.input, res.Inspect())
+			}
+			continue
+		}
+
+		errObj, ok := res.(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error but found %v", tt.input, res)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: expected %q but found %q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestOverloadedOnStatements(t *testing.T) {
+	tokenABI, err := ethereum.NewABI(`[
+		{"type": "event", "name": "Transfer", "inputs": [
+			{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}
+		]},
+		{"type": "event", "name": "Transfer", "inputs": [
+			{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256"}, {"name": "data", "type": "bytes"}
+		]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `on Token.Transfer(from, to, value) {}
+on Token.Transfer(from, to, value, data) {}
+on Token.Transfer(from, to=0x2222222222222222222222222222222222222222, value) {}
+`
+	env := object.NewEnvironment()
+	env.Set("Token", &object.Contract{Name: "Token", ABI: tokenABI})
+	if res := Eval(parser.New(lexer.New(input)).ParseProgram(), env); isError(res) {
+		t.Fatal(res.Inspect())
+	}
+
+	ids := []string{}
+	for _, event := range env.GetOnStatements() {
+		ids = append(ids, event.ID)
+	}
+	sort.Strings(ids)
+
+	expected := []string{
+		"Token.Transfer(address,address,uint256)",
+		"Token.Transfer(address,address,uint256)#2",
+		"Token.Transfer(address,address,uint256,bytes)",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected %v but found %v", expected, ids)
+	}
+}
+
+func TestApplyEventErrors(t *testing.T) {
+	input := `
+artifact ("ERC20")
+
+on ERC20.Transfer(from, to, value) {
+	if (value == 1) {
+		return value + true;
+	}
+	if (value == 2) {
+		crash();
+	}
+	value
+}
+`
+	env := object.NewEnvironment()
+	env.AddBuiltin("crash", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		panic("crashed")
+	}})
+	if res := Eval(parser.New(lexer.NewFile("erc20.hra", input)).ParseProgram(), env); isError(res) {
+		t.Fatal(res.Inspect())
+	}
+	event := env.GetOnStatements()[0]
+
+	tests := []struct {
+		value int64
+		err   string
+	}{
+		{0, ""},
+		{1, "erc20.hra:6:16: type mismatch: INTEGER + BOOLEAN\n\tat on ERC20.Transfer (erc20.hra:4:36)"},
+		{2, "panic: crashed"},
+	}
+
+	for _, tt := range tests {
+		args := []object.Object{
+			&object.Address{},
+			&object.Address{},
+			&object.Integer{Value: big.NewInt(tt.value)},
+		}
+
+		res, err := ApplyEvent(*event, args, &web3.Log{})
+		if tt.err == "" {
+			if err != nil {
+				t.Fatal(err)
+			}
+			testIntegerObject(t, res, tt.value)
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Fatalf("expected error %q but found %v", tt.err, err)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = 1;\nlet y = x + true;",
+			"test.hra:2:11: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let x = 1;\n  foo(x);",
+			"test.hra:2:3: identifier not found: foo",
+		},
+		{
+			"let a = fn(x) {\n\treturn x / 0;\n};\nlet b = fn(x) { a(x) };\nb(1);",
+			"test.hra:2:11: division by zero\n\tat a (test.hra:4:17)\n\tat b (test.hra:5:1)",
+		},
+		{
+			"let h = {\"f\": fn() { -true }};\nh.f();",
+			"test.hra:1:22: unknown operator: -BOOLEAN\n\tat f (test.hra:2:3)",
+		},
+		{
+			"let f = fn(x) { x };\nf(1, 2);",
+			"test.hra:2:1: length or parameters not correct",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.NewFile("test.hra", tt.input)).ParseProgram()
+		evaluated := Eval(program, object.NewEnvironment())
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		}
+		if errObj.StackTrace() != tt.expected {
+			t.Errorf("wrong stack trace.\nexpected=%q\ngot=%q", tt.expected, errObj.StackTrace())
+		}
+	}
+}
+
+func TestOnHooks(t *testing.T) {
+	env := object.NewEnvironment()
+	input := `
+let count = 1;
+on shutdown {
+	count + 1
+}
+`
+	if res := Eval(parser.New(lexer.New(input)).ParseProgram(), env); isError(res) {
+		t.Fatal(res.Inspect())
+	}
+
+	hook := env.GetHook("shutdown")
+	if hook == nil {
+		t.Fatal("hook not found")
+	}
+	res, err := ApplyHook(hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, res, 2)
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"on startup {}", "unknown hook startup"},
+		{"on shutdown {} on shutdown {}", "hook shutdown already defined"},
+	}
+	for _, tt := range errors {
+		res := testEval(tt.input)
+		errObj, ok := res.(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Fatalf("expected error %q but found %v", tt.expected, res)
+		}
+	}
+}
+
 // Private functions from here
 
 func testEval(input string) object.Object {
@@ -0,0 +1,182 @@
+package evaluator
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/umbracle/go-web3/jsonrpc"
+
+	"github.com/umbracle/heura/heura/object"
+	"github.com/umbracle/heura/heura/wallet"
+)
+
+// feeHistoryBlocks is the number of blocks used to suggest the tip
+const feeHistoryBlocks = 10
+
+// txOptions are the options of send, i.e. send({gas: 100000, tip: 2 gwei})
+type txOptions struct {
+	gas      *big.Int
+	gasPrice *big.Int
+	tip      *big.Int
+	maxFee   *big.Int
+	value    *big.Int
+
+	// replaceAfter is the time before the transaction is replaced, zero
+	// if it is never replaced
+	replaceAfter time.Duration
+}
+
+func parseTxOptions(hash *object.Hash) (*txOptions, *object.Error) {
+	opts := &txOptions{replaceAfter: replaceAfter}
+
+	for _, pair := range sortedPairs(hash) {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return nil, newError("option of `send` must be STRING, got %s", pair.Key.Type())
+		}
+		value, ok := pair.Value.(*object.Integer)
+		if !ok {
+			return nil, newError("option %s of `send` must be INTEGER, got %s", key.Value, pair.Value.Type())
+		}
+		if value.Value.Sign() < 0 {
+			return nil, newError("option %s of `send` cannot be negative, got %s", key.Value, value.Value)
+		}
+
+		switch key.Value {
+		case "gas":
+			if !value.Value.IsUint64() {
+				return nil, newError("option gas of `send` overflows uint64, got %s", value.Value)
+			}
+			opts.gas = value.Value
+		case "gasPrice":
+			opts.gasPrice = value.Value
+		case "tip":
+			opts.tip = value.Value
+		case "maxFee":
+			opts.maxFee = value.Value
+		case "value":
+			opts.value = value.Value
+		case "replaceAfter":
+			// in seconds
+			if value.Value.Cmp(big.NewInt(int64(receiptTimeout/time.Second))) > 0 {
+				return nil, newError("option replaceAfter of `send` is longer than the timeout of %s", receiptTimeout)
+			}
+			opts.replaceAfter = time.Duration(value.Value.Int64()) * time.Second
+		default:
+			return nil, newError("unknown option %s of `send`", key.Value)
+		}
+	}
+
+	if opts.gasPrice != nil && (opts.tip != nil || opts.maxFee != nil) {
+		return nil, newError("option gasPrice of `send` cannot be used with tip or maxFee")
+	}
+	if opts.tip != nil && opts.maxFee != nil && opts.tip.Cmp(opts.maxFee) > 0 {
+		return nil, newError("tip %s is higher than maxFee %s", opts.tip, opts.maxFee)
+	}
+	return opts, nil
+}
+
+// setFees sets the fees of the transaction. If the chain supports EIP-1559
+// it is a dynamic fee transaction. The tip is the median of the tips paid
+// in the last blocks and the max fee is twice the base fee of the next
+// block plus the tip. Otherwise, or with the gasPrice option, it is a
+// legacy transaction with the gas price of the node.
+func setFees(client *jsonrpc.Client, tx *wallet.Transaction, opts *txOptions) error {
+	if opts.gasPrice != nil {
+		tx.GasPrice = opts.gasPrice
+		return nil
+	}
+
+	baseFee, tip, err := feeHistory(client)
+	if err != nil {
+		if opts.tip != nil || opts.maxFee != nil {
+			return fmt.Errorf("the chain does not support EIP-1559 fees, use gasPrice instead: %v", err)
+		}
+		gasPrice, err := client.Eth().GasPrice()
+		if err != nil {
+			return fmt.Errorf("failed to get the gas price: %v", err)
+		}
+		tx.GasPrice = new(big.Int).SetUint64(gasPrice)
+		return nil
+	}
+
+	if opts.tip != nil {
+		tip = opts.tip
+	}
+	maxFee := opts.maxFee
+	if maxFee == nil {
+		maxFee = new(big.Int).Lsh(baseFee, 1)
+		maxFee.Add(maxFee, tip)
+	} else if tip.Cmp(maxFee) > 0 {
+		// the suggested tip is higher than the max fee of the script
+		tip = maxFee
+	}
+
+	tx.MaxPriorityFeePerGas = tip
+	tx.MaxFeePerGas = maxFee
+	return nil
+}
+
+// feeHistory returns the base fee of the next block and the median of the
+// tips paid in the last blocks
+func feeHistory(client *jsonrpc.Client) (*big.Int, *big.Int, error) {
+	var res struct {
+		BaseFeePerGas []string
+		Reward        [][]string
+	}
+	if err := client.Call("eth_feeHistory", &res, fmt.Sprintf("0x%x", feeHistoryBlocks), "latest", []int{50}); err != nil {
+		return nil, nil, err
+	}
+
+	// the last base fee is the one of the next block. It is zero in the
+	// blocks before London
+	if len(res.BaseFeePerGas) == 0 {
+		return nil, nil, fmt.Errorf("no base fee found")
+	}
+	baseFee, ok := parseBigQuantity(res.BaseFeePerGas[len(res.BaseFeePerGas)-1])
+	if !ok || baseFee.Sign() == 0 {
+		return nil, nil, fmt.Errorf("no base fee found")
+	}
+
+	tips := []*big.Int{}
+	for _, reward := range res.Reward {
+		if len(reward) == 0 {
+			continue
+		}
+		if tip, ok := parseBigQuantity(reward[0]); ok {
+			tips = append(tips, tip)
+		}
+	}
+	if len(tips) == 0 {
+		return baseFee, new(big.Int), nil
+	}
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].Cmp(tips[j]) < 0
+	})
+	return baseFee, tips[len(tips)/2], nil
+}
+
+// bumpFees raises the fees of the transaction by 12.5% to replace it, the
+// nodes require at least 10%
+func bumpFees(tx *wallet.Transaction) {
+	bump := func(fee *big.Int) *big.Int {
+		res := new(big.Int).Rsh(fee, 3)
+		res.Add(res, fee)
+		return res.Add(res, big.NewInt(1))
+	}
+
+	if tx.IsDynamicFee() {
+		tx.MaxFeePerGas = bump(tx.MaxFeePerGas)
+		tx.MaxPriorityFeePerGas = bump(tx.MaxPriorityFeePerGas)
+	} else {
+		tx.GasPrice = bump(tx.GasPrice)
+	}
+}
+
+// parseBigQuantity parses a hex encoded quantity of the json-rpc api
+// with arbitrary precision
+func parseBigQuantity(str string) (*big.Int, bool) {
+	return new(big.Int).SetString(trimHexPrefix(str), 16)
+}
@@ -0,0 +1,302 @@
+package evaluator
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/umbracle/heura/heura/object"
+)
+
+// format(format, args...) formats the arguments like fmt.Sprintf. Integers
+// support the integer verbs (%d, %x...) and decimals the float verbs (%f, %e...)
+func builtinFormat(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+	}
+
+	format, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `format` must be STRING, got %s", args[0].Type())
+	}
+
+	values := make([]interface{}, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		values = append(values, formatArg(arg))
+	}
+	return &object.String{Value: fmt.Sprintf(format.Value, values...)}
+}
+
+// formatArg returns the Go value used to format an object
+func formatArg(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Decimal:
+		return decimalArg{obj}
+	case *object.Boolean:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	default:
+		return obj.Inspect()
+	}
+}
+
+// decimalArg formats a decimal as a float with the float verbs and as
+// its exact value otherwise
+type decimalArg struct {
+	*object.Decimal
+}
+
+func (d decimalArg) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		new(big.Float).SetPrec(256).SetRat(d.Value).Format(s, verb)
+		return
+	}
+
+	str := d.Inspect()
+	if width, ok := s.Width(); ok && len(str) < width {
+		pad := strings.Repeat(" ", width-len(str))
+		if s.Flag('-') {
+			str += pad
+		} else {
+			str = pad + str
+		}
+	}
+	fmt.Fprint(s, str)
+}
+
+// stringArgs checks that there are between min and max arguments and all of
+// them are strings
+func stringArgs(name string, args []object.Object, min, max int) ([]string, *object.Error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), min)
+		}
+		return nil, newError("wrong number of arguments. got=%d, want=%d or %d", len(args), min, max)
+	}
+
+	res := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+		res[i] = str.Value
+	}
+	return res, nil
+}
+
+// split(s, sep) splits the string around each instance of sep
+func builtinSplit(args ...object.Object) object.Object {
+	strs, err := stringArgs("split", args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(strs[0], strs[1])
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// join(array, sep) concatenates the elements of the array with sep in between
+func builtinJoin(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `join` must be ARRAY, got %s", args[0].Type())
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newError("argument to `join` must be STRING, got %s", args[1].Type())
+	}
+
+	parts := make([]string, len(arr.Elements))
+	for i, elem := range arr.Elements {
+		parts[i] = elem.Inspect()
+	}
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+func builtinStartsWith(args ...object.Object) object.Object {
+	strs, err := stringArgs("startsWith", args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasPrefix(strs[0], strs[1]))
+}
+
+func builtinEndsWith(args ...object.Object) object.Object {
+	strs, err := stringArgs("endsWith", args, 2, 2)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(strings.HasSuffix(strs[0], strs[1]))
+}
+
+// replace(s, old, new) replaces all the instances of old with new
+func builtinReplace(args ...object.Object) object.Object {
+	strs, err := stringArgs("replace", args, 3, 3)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Replace(strs[0], strs[1], strs[2], -1)}
+}
+
+// trim(s) removes the leading and trailing white space. trim(s, cutset)
+// removes the leading and trailing characters in cutset instead
+func builtinTrim(args ...object.Object) object.Object {
+	strs, err := stringArgs("trim", args, 1, 2)
+	if err != nil {
+		return err
+	}
+	if len(strs) == 2 {
+		return &object.String{Value: strings.Trim(strs[0], strs[1])}
+	}
+	return &object.String{Value: strings.TrimSpace(strs[0])}
+}
+
+func builtinUpper(args ...object.Object) object.Object {
+	strs, err := stringArgs("upper", args, 1, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToUpper(strs[0])}
+}
+
+func builtinLower(args ...object.Object) object.Object {
+	strs, err := stringArgs("lower", args, 1, 1)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: strings.ToLower(strs[0])}
+}
+
+// substring(s, start, end) is the same as s[start:end]. Without end, it
+// returns the rest of the string
+func builtinSubstring(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `substring` must be STRING, got %s", args[0].Type())
+	}
+
+	var end object.Object
+	if len(args) == 3 {
+		end = args[2]
+	}
+	start, stop, err := sliceRange(args[1], end, len(str.Value))
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: str.Value[start:stop]}
+}
+
+// toInt(x) converts a decimal or hex string, bytes, an address or a
+// decimal to an integer. Decimals are truncated towards zero
+func builtinToInt(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Decimal:
+		return &object.Integer{Value: new(big.Int).Quo(arg.Value.Num(), arg.Value.Denom())}
+	case *object.String:
+		value, ok := parseInt(strings.TrimSpace(arg.Value))
+		if !ok {
+			return newError("could not parse %q as integer", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	case *object.Bytes:
+		return hexToInt(arg.Value)
+	case *object.Address:
+		return hexToInt(arg.Value)
+	default:
+		return newError("argument to `toInt` not supported, got %s", args[0].Type())
+	}
+}
+
+// parseInt parses a decimal or a 0x prefixed hex integer
+func parseInt(str string) (*big.Int, bool) {
+	neg := strings.HasPrefix(str, "-")
+	if neg {
+		str = str[1:]
+	}
+
+	var value *big.Int
+	var ok bool
+	if hex := trimHexPrefix(str); hex != str {
+		value, ok = new(big.Int).SetString(hex, 16)
+	} else {
+		value, ok = new(big.Int).SetString(str, 10)
+	}
+	// SetString accepts a sign, which is only valid before the prefix
+	if !ok || (str != "" && (str[0] == '+' || str[0] == '-')) {
+		return nil, false
+	}
+	if neg {
+		value.Neg(value)
+	}
+	return value, true
+}
+
+func hexToInt(str string) object.Object {
+	str = trimHexPrefix(str)
+	if str == "" {
+		return &object.Integer{Value: big.NewInt(0)}
+	}
+	value, ok := new(big.Int).SetString(str, 16)
+	if !ok {
+		return newError("could not parse %q as hex", str)
+	}
+	return &object.Integer{Value: value}
+}
+
+func trimHexPrefix(str string) string {
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		return str[2:]
+	}
+	return str
+}
+
+// toString(x) returns the string representation of any value. Integers
+// can be written in another base with toString(x, base)
+func builtinToString(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	if len(args) == 2 {
+		value, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError("argument to `toString` with a base must be INTEGER, got %s", args[0].Type())
+		}
+		base, ok := args[1].(*object.Integer)
+		if !ok {
+			return newError("base must be INTEGER, got %s", args[1].Type())
+		}
+		if base.Value.Cmp(big.NewInt(2)) < 0 || base.Value.Cmp(big.NewInt(big.MaxBase)) > 0 {
+			return newError("base must be between 2 and %d, got %s", big.MaxBase, base.Value)
+		}
+		return &object.String{Value: value.Value.Text(int(base.Value.Int64()))}
+	}
+
+	if str, ok := args[0].(*object.String); ok {
+		return str
+	}
+	return &object.String{Value: args[0].Inspect()}
+}
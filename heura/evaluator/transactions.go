@@ -0,0 +1,408 @@
+package evaluator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/umbracle/go-web3"
+	"github.com/umbracle/go-web3/jsonrpc"
+
+	"github.com/umbracle/heura/heura/ast"
+	"github.com/umbracle/heura/heura/encoding"
+	"github.com/umbracle/heura/heura/ethereum"
+	"github.com/umbracle/heura/heura/object"
+	"github.com/umbracle/heura/heura/token"
+	"github.com/umbracle/heura/heura/wallet"
+)
+
+var (
+	// receiptPollInterval is the time between the queries for the
+	// receipt of a sent transaction
+	receiptPollInterval = 2 * time.Second
+
+	// receiptTimeout is the time to wait for the receipt
+	receiptTimeout = 10 * time.Minute
+
+	// replaceAfter is the time to wait for a transaction to be included
+	// before it is replaced with higher fees
+	replaceAfter = 3 * time.Minute
+
+	// maxReplacements is the number of times a transaction is replaced
+	maxReplacements = 5
+)
+
+// Account(address) returns an account that can be queried. Account(key),
+// with a hex private key, i.e. Account(env["PRIVATE_KEY"]), and
+// Account(keystore, password), with the path of a keystore file, return
+// an account that sends transactions too
+func builtinAccount(args ...object.Object) object.Object {
+	switch len(args) {
+	case 1:
+		var key string
+		switch arg := args[0].(type) {
+		case *object.String:
+			key = arg.Value
+		case *object.Bytes:
+			// 32 bytes are a key, 20 bytes an address
+			if len(trimHexPrefix(arg.Value)) == 64 {
+				key = arg.Value
+			}
+		}
+		if key != "" {
+			signer, err := wallet.NewKeyFromHex(key)
+			if err != nil {
+				return newError("%v", err)
+			}
+			return object.NewSigner(signer)
+		}
+
+		account, err := object.NewAccount(args[0])
+		if err != nil {
+			return newError("%v", err)
+		}
+		return account
+
+	case 2:
+		path, ok := args[0].(*object.String)
+		if !ok {
+			return newError("keystore path must be STRING, got %s", args[0].Type())
+		}
+		password, ok := args[1].(*object.String)
+		if !ok {
+			return newError("keystore password must be STRING, got %s", args[1].Type())
+		}
+
+		signer, err := wallet.ReadKeystore(path.Value, password.Value)
+		if err != nil {
+			return newError("failed to read keystore %s: %v", path.Value, err)
+		}
+		return object.NewSigner(signer)
+
+	default:
+		return newError("expected one or two parameters but found %d", len(args))
+	}
+}
+
+// sendCall returns the method call and the send call of a transaction,
+// i.e. transfer(to, value) and send() in transfer(to, value).send()
+func sendCall(expr ast.Expression) (*ast.CallExpression, *ast.CallExpression, bool) {
+	index, ok := expr.(*ast.IndexExpression)
+	if !ok || index.TokenLiteral() != token.DOT {
+		return nil, nil, false
+	}
+	call, ok := index.Left.(*ast.CallExpression)
+	if !ok {
+		return nil, nil, false
+	}
+	send, ok := index.Index.(*ast.CallExpression)
+	if !ok {
+		return nil, nil, false
+	}
+	if name, ok := send.Function.(*ast.Identifier); !ok || name.Value != "send" {
+		return nil, nil, false
+	}
+	return call, send, true
+}
+
+// evalExplicitSend sends the transaction of an explicit method, i.e.
+// token["transfer(address,uint256)"](to, value).send(). It returns false
+// if the expression is not one.
+func evalExplicitSend(node *ast.IndexExpression, env *object.Environment) (object.Object, bool) {
+	call, send, ok := sendCall(node)
+	if !ok {
+		return nil, false
+	}
+	method, ok := call.Function.(*ast.IndexExpression)
+	if !ok || method.TokenLiteral() == token.DOT {
+		return nil, false
+	}
+
+	left := Eval(method.Left, env)
+	if isError(left) {
+		return left, true
+	}
+	instance, ok := left.(*object.Instance)
+	if !ok {
+		return newError("send is only supported on contract methods, got %s", left.Type()), true
+	}
+
+	index := Eval(method.Index, env)
+	if isError(index) {
+		return index, true
+	}
+	sig, ok := index.(*object.String)
+	if !ok {
+		return newError("method of a transaction must be STRING, got %s", index.Type()), true
+	}
+
+	return evalSendTransaction(instance, sig.Value, call, send, env), true
+}
+
+// evalSendTransaction evaluates the arguments of the method and of the send
+// call and sends the transaction. The send call is send(), send(account),
+// send(options) or send(account, options)
+func evalSendTransaction(instance *object.Instance, name string, call, send *ast.CallExpression, env *object.Environment) object.Object {
+	args := evalExpressions(call.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	sendArgs := evalSendArgs(send.Arguments, env)
+	if len(sendArgs) == 1 && isError(sendArgs[0]) {
+		return sendArgs[0]
+	}
+	if len(sendArgs) > 2 {
+		return newError("wrong number of arguments. got=%d, want at most 2", len(sendArgs))
+	}
+
+	var account *object.Account
+	if len(sendArgs) > 0 {
+		if arg, ok := sendArgs[0].(*object.Account); ok {
+			account = arg
+			sendArgs = sendArgs[1:]
+		}
+	}
+
+	opts := &txOptions{replaceAfter: replaceAfter}
+	if len(sendArgs) > 0 {
+		hash, ok := sendArgs[0].(*object.Hash)
+		if !ok {
+			return newError("argument to `send` must be ACCOUNT or HASH, got %s", sendArgs[0].Type())
+		}
+		if len(sendArgs) > 1 {
+			return newError("the options must be the last argument of `send`, found %s", sendArgs[1].Type())
+		}
+		var err *object.Error
+		if opts, err = parseTxOptions(hash); err != nil {
+			return err
+		}
+	}
+
+	if account == nil {
+		signer, err := env.GetSigner()
+		if err != nil {
+			return newError("%v", err)
+		}
+		account = signer
+	}
+	if account.Key == nil {
+		return newError("account %s has no key to send transactions", account.Addr.String())
+	}
+	return sendTransaction(instance, name, args, account, opts, env)
+}
+
+// evalSendArgs evaluates the arguments of send. The keys of a hash
+// literal can be written without quotes, i.e. send({gas: 100000})
+func evalSendArgs(exps []ast.Expression, env *object.Environment) []object.Object {
+	res := []object.Object{}
+	for _, exp := range exps {
+		if lit, ok := exp.(*ast.HashLiteral); ok {
+			pairs := map[ast.Expression]ast.Expression{}
+			for k, v := range lit.Pairs {
+				if ident, ok := k.(*ast.Identifier); ok {
+					k = &ast.StringLiteral{Token: ident.Token, Value: ident.Value}
+				}
+				pairs[k] = v
+			}
+			exp = &ast.HashLiteral{Token: lit.Token, Pairs: pairs}
+		}
+
+		evaluated := Eval(exp, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		res = append(res, evaluated)
+	}
+	return res
+}
+
+// sendTransaction sends a transaction from the account that calls the
+// method of the contract and waits for its receipt
+func sendTransaction(instance *object.Instance, name string, args []object.Object, account *object.Account, opts *txOptions, env *object.Environment) object.Object {
+	method, err := ethereum.FindMethod(instance.ABI, name, args)
+	if err != nil {
+		return newError("%v", err)
+	}
+	data, err := encoding.Pack(method.Inputs, args)
+	if err != nil {
+		return newError("%v", err)
+	}
+	data = append(method.ID(), data...)
+
+	rpcEndpoint, err := env.GetRPCEndpoint()
+	if err != nil {
+		return newError("%v", err)
+	}
+	client, err := jsonrpc.NewClient(rpcEndpoint)
+	if err != nil {
+		return newError("%v", err)
+	}
+	defer client.Close()
+
+	chainID, err := client.Eth().ChainID()
+	if err != nil {
+		return newError("failed to get the chain id: %v", err)
+	}
+
+	tx := &wallet.Transaction{
+		To:      instance.Address,
+		Value:   opts.value,
+		Data:    data,
+		ChainID: chainID,
+	}
+
+	if opts.gas != nil {
+		tx.Gas = opts.gas.Uint64()
+	} else {
+		msg := map[string]interface{}{
+			"from": account.Addr.String(),
+			"to":   instance.Address.String(),
+			"data": "0x" + hex.EncodeToString(data),
+		}
+		if opts.value != nil {
+			msg["value"] = fmt.Sprintf("0x%x", opts.value)
+		}
+		var gas string
+		if err := client.Call("eth_estimateGas", &gas, msg); err != nil {
+			return newError("failed to estimate gas of %s: %v", method.Sig(), err)
+		}
+		if tx.Gas, err = parseQuantity(gas); err != nil {
+			return newError("%v", err)
+		}
+	}
+
+	if err := setFees(client, tx, opts); err != nil {
+		return newError("%v", err)
+	}
+
+	var hash web3.Hash
+	pending := func() (uint64, error) {
+		return client.Eth().GetNonce(account.Addr, web3.Pending)
+	}
+	err = account.UseNonce(pending, func(nonce uint64) error {
+		tx.Nonce = nonce
+		hash, err = sendRawTransaction(client, account.Key, tx)
+		return err
+	})
+	if err != nil {
+		return newError("failed to send transaction: %v", err)
+	}
+
+	// the fees set by the script are not raised
+	replace := opts.replaceAfter
+	if opts.gasPrice != nil || opts.maxFee != nil {
+		replace = 0
+	}
+
+	receipt, err := waitForReceipt(client, account.Key, tx, hash, replace)
+	if err != nil {
+		return newError("%v", err)
+	}
+	if receipt.Status == "0x0" {
+		return newError("transaction %s reverted", receipt.TransactionHash.String())
+	}
+	return receipt.object()
+}
+
+// sendRawTransaction signs and sends the transaction
+func sendRawTransaction(client *jsonrpc.Client, key *wallet.Key, tx *wallet.Transaction) (web3.Hash, error) {
+	raw, hash := key.SignTx(tx)
+
+	var res web3.Hash
+	if err := client.Call("eth_sendRawTransaction", &res, "0x"+hex.EncodeToString(raw)); err != nil {
+		return hash, err
+	}
+	return hash, nil
+}
+
+// receipt is the receipt of a transaction, the receipt of web3 does not
+// decode the status. The status is empty before Byzantium
+type receipt struct {
+	TransactionHash web3.Hash
+	BlockHash       web3.Hash
+	BlockNumber     string
+	GasUsed         string
+	Status          string
+}
+
+func (r *receipt) object() object.Object {
+	res := &object.Hash{Pairs: map[object.HashKey]object.HashPair{}}
+
+	blockNumber, _ := parseQuantity(r.BlockNumber)
+	gasUsed, _ := parseQuantity(r.GasUsed)
+
+	res.SetString("txhash", &object.String{Value: hex.EncodeToString(r.TransactionHash[:])})
+	res.SetString("blockhash", &object.String{Value: hex.EncodeToString(r.BlockHash[:])})
+	res.SetString("blocknumber", &object.Integer{Value: new(big.Int).SetUint64(blockNumber)})
+	res.SetString("gasused", &object.Integer{Value: new(big.Int).SetUint64(gasUsed)})
+	return res
+}
+
+// waitForReceipt polls the receipt of the transaction until it is included
+// in a block. If it is not included after the replace interval, the
+// transaction is sent again with higher fees, up to maxReplacements times.
+// Any of the transactions sent can be the one included. If the node rejects
+// a replacement, the error is printed and the transactions already sent are
+// the only ones waited for.
+func waitForReceipt(client *jsonrpc.Client, key *wallet.Key, tx *wallet.Transaction, hash web3.Hash, replace time.Duration) (*receipt, error) {
+	hashes := []web3.Hash{hash}
+	timeout := time.After(receiptTimeout)
+	lastSent := time.Now()
+
+	var replaceErr error
+	for {
+		for _, hash := range hashes {
+			var res *receipt
+			if err := client.Call("eth_getTransactionReceipt", &res, hash); err != nil {
+				return nil, fmt.Errorf("failed to get the receipt of %s: %v", hash.String(), err)
+			}
+			if res != nil && res.BlockNumber != "" {
+				return res, nil
+			}
+		}
+
+		if replace > 0 && replaceErr == nil && len(hashes) <= maxReplacements && time.Since(lastSent) >= replace {
+			bumpFees(tx)
+			hash, err := sendRawTransaction(client, key, tx)
+			if err == nil {
+				hashes = append(hashes, hash)
+			} else if isNonceUsed(err) {
+				// a transaction was included in the meantime, its receipt
+				// is found in the next query
+				replace = 0
+			} else {
+				replaceErr = err
+				fmt.Printf("failed to replace transaction %s: %v\n", hashes[len(hashes)-1].String(), err)
+			}
+			lastSent = time.Now()
+		}
+
+		select {
+		case <-time.After(receiptPollInterval):
+		case <-timeout:
+			if replaceErr != nil {
+				return nil, fmt.Errorf("transaction %s not included after %s, its replacement failed: %v", hashes[len(hashes)-1].String(), receiptTimeout, replaceErr)
+			}
+			return nil, fmt.Errorf("transaction %s not included after %s", hashes[len(hashes)-1].String(), receiptTimeout)
+		}
+	}
+}
+
+// isNonceUsed returns whether the node rejected a transaction because its
+// nonce was already used by an included transaction
+func isNonceUsed(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "nonce too low")
+}
+
+// parseQuantity parses a hex encoded quantity of the json-rpc api
+func parseQuantity(str string) (uint64, error) {
+	num, err := strconv.ParseUint(trimHexPrefix(str), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse quantity %q: %v", str, err)
+	}
+	return num, nil
+}
@@ -0,0 +1,375 @@
+package evaluator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/umbracle/go-web3"
+	"github.com/umbracle/go-web3/jsonrpc/codec"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/umbracle/heura/heura/lexer"
+	"github.com/umbracle/heura/heura/object"
+	"github.com/umbracle/heura/heura/parser"
+	"github.com/umbracle/heura/heura/wallet"
+)
+
+const testPrivateKey = "0x4646464646464646464646464646464646464646464646464646464646464646"
+
+// testNode is a jsonrpc endpoint that accepts raw transactions
+type testNode struct {
+	srv *httptest.Server
+
+	lock sync.Mutex
+	// nonce is the pending nonce of every account
+	nonce uint64
+	// status is the status of the receipts
+	status string
+	// baseFee is the base fee of the next block, the node does not
+	// support EIP-1559 if it is empty
+	baseFee string
+	// stuck are the indexes of the transactions that are never included
+	stuck map[int]bool
+	// txs are the raw transactions sent
+	txs []string
+	// sends is the number of eth_sendRawTransaction requests
+	sends int
+	// reject are the errors of the eth_sendRawTransaction requests by index
+	reject map[int]string
+	// queries is the number of receipt queries of each transaction
+	queries map[string]int
+}
+
+func newTestNode() *testNode {
+	n := &testNode{status: "0x1", queries: map[string]int{}}
+	n.srv = httptest.NewServer(http.HandlerFunc(n.handle))
+	return n
+}
+
+func (n *testNode) handle(w http.ResponseWriter, r *http.Request) {
+	var req codec.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return
+	}
+	var params []json.RawMessage
+	json.Unmarshal(req.Params, &params)
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	var result interface{}
+	switch req.Method {
+	case "eth_chainId":
+		result = "0x1"
+	case "eth_getTransactionCount":
+		result = fmt.Sprintf("0x%x", n.nonce)
+	case "eth_estimateGas":
+		result = "0x5208"
+	case "eth_gasPrice":
+		result = "0x4a817c800"
+	case "eth_feeHistory":
+		if n.baseFee == "" {
+			w.Write([]byte(fmt.Sprintf(`{"id": %d, "error": {"code": -32601, "message": "method eth_feeHistory not found"}}`, req.ID)))
+			return
+		}
+		result = map[string]interface{}{
+			"baseFeePerGas": []string{"0x1", n.baseFee},
+			"reward":        [][]string{{"0x77359400"}, {"0x0"}, {"0xb2d05e00"}},
+		}
+	case "eth_sendRawTransaction":
+		n.sends++
+		if msg, ok := n.reject[n.sends-1]; ok {
+			w.Write([]byte(fmt.Sprintf(`{"id": %d, "error": {"code": -32000, "message": %q}}`, req.ID, msg)))
+			return
+		}
+		var raw string
+		json.Unmarshal(params[0], &raw)
+		n.txs = append(n.txs, raw)
+		result = testTxHash(raw)
+	case "eth_getTransactionReceipt":
+		var hash string
+		json.Unmarshal(params[0], &hash)
+		// the transaction is included at the second query
+		n.queries[hash]++
+		if n.queries[hash] > 1 && !n.isStuck(hash) {
+			result = map[string]interface{}{
+				"transactionHash": hash,
+				"blockHash":       "0x" + hex.EncodeToString(make([]byte, 32)),
+				"blockNumber":     "0x10",
+				"gasUsed":         "0x5208",
+				"status":          n.status,
+			}
+		}
+	default:
+		w.Write([]byte(fmt.Sprintf(`{"id": %d, "error": {"code": -32601, "message": "method %s not found"}}`, req.ID, req.Method)))
+		return
+	}
+
+	data, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(&codec.Response{ID: req.ID, Result: data})
+}
+
+func (n *testNode) isStuck(hash string) bool {
+	for i, tx := range n.txs {
+		if testTxHash(tx) == hash {
+			return n.stuck[i]
+		}
+	}
+	return false
+}
+
+// testTxHash returns the hash of a raw transaction
+func testTxHash(raw string) string {
+	b, _ := hex.DecodeString(raw[2:])
+	h := sha3.NewLegacyKeccak256()
+	h.Write(b)
+	return "0x" + hex.EncodeToString(h.Sum(nil))
+}
+
+// testTransferTx returns a transaction of transfer(0x11..11, 100) to
+// the contract 0x35..35
+func testTransferTx(nonce uint64) *wallet.Transaction {
+	data, _ := hex.DecodeString("a9059cbb" +
+		"0000000000000000000000001111111111111111111111111111111111111111" +
+		"0000000000000000000000000000000000000000000000000000000000000064")
+
+	return &wallet.Transaction{
+		Nonce:   nonce,
+		Gas:     21000,
+		To:      web3.HexToAddress("0x3535353535353535353535353535353535353535"),
+		Data:    data,
+		ChainID: big.NewInt(1),
+	}
+}
+
+func testSignedTx(t *testing.T, tx *wallet.Transaction) string {
+	key, err := wallet.NewKeyFromHex(testPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := key.SignTx(tx)
+	return "0x" + hex.EncodeToString(raw)
+}
+
+func TestSendTransaction(t *testing.T) {
+	receiptPollInterval = time.Millisecond
+
+	node := newTestNode()
+	defer node.srv.Close()
+	node.nonce = 5
+
+	input := `
+artifact ("ERC20")
+
+let signer = Account("` + testPrivateKey + `");
+let token = ERC20(0x3535353535353535353535353535353535353535);
+
+let receipt = token.transfer(0x1111111111111111111111111111111111111111, 100).send();
+token["transfer(address,uint256)"](0x1111111111111111111111111111111111111111, 100).send(signer);
+receipt
+`
+	env := object.NewEnvironment()
+	env.Set("endpoint", &object.String{Value: node.srv.URL})
+
+	res := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	if isError(res) {
+		t.Fatal(res.Inspect())
+	}
+
+	receipt, ok := res.(*object.Hash)
+	if !ok {
+		t.Fatalf("expected a hash but found %s", res.Type())
+	}
+	blockNumber, _ := receipt.GetString("blocknumber")
+	testIntegerObject(t, blockNumber, 16)
+	gasUsed, _ := receipt.GetString("gasused")
+	testIntegerObject(t, gasUsed, 21000)
+
+	// the node does not update the pending nonce, the second transaction
+	// uses the nonce after the first one
+	expected := []string{}
+	for nonce := uint64(5); nonce < 7; nonce++ {
+		tx := testTransferTx(nonce)
+		tx.GasPrice = big.NewInt(20000000000)
+		expected = append(expected, testSignedTx(t, tx))
+	}
+	if len(node.txs) != len(expected) {
+		t.Fatalf("expected %d transactions but found %d", len(expected), len(node.txs))
+	}
+	for i := range expected {
+		if node.txs[i] != expected[i] {
+			t.Errorf("transaction %d: expected %s but found %s", i, expected[i], node.txs[i])
+		}
+	}
+}
+
+func TestSendDynamicFeeTransaction(t *testing.T) {
+	receiptPollInterval = time.Millisecond
+	defer func(d time.Duration) { replaceAfter = d }(replaceAfter)
+	replaceAfter = 50 * time.Millisecond
+
+	node := newTestNode()
+	defer node.srv.Close()
+	node.baseFee = "0x3b9aca00"
+	node.stuck = map[int]bool{2: true}
+
+	input := `
+artifact ("ERC20")
+
+let signer = Account("` + testPrivateKey + `");
+let token = ERC20(0x3535353535353535353535353535353535353535);
+
+token.transfer(0x1111111111111111111111111111111111111111, 100).send({gas: 21000, tip: 3 gwei, maxFee: 10 gwei});
+token.transfer(0x1111111111111111111111111111111111111111, 100).send(signer, {"gasPrice": 30 gwei});
+token.transfer(0x1111111111111111111111111111111111111111, 100).send();
+`
+	env := object.NewEnvironment()
+	env.Set("endpoint", &object.String{Value: node.srv.URL})
+
+	res := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	if isError(res) {
+		t.Fatal(res.Inspect())
+	}
+
+	gwei := func(i int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(i), big.NewInt(1000000000))
+	}
+
+	// the transactions with the fees of the script are not replaced
+	first := testTransferTx(0)
+	first.MaxPriorityFeePerGas = gwei(3)
+	first.MaxFeePerGas = gwei(10)
+
+	second := testTransferTx(1)
+	second.GasPrice = gwei(30)
+
+	// the tip is the median of 2, 0 and 3 gwei and the max fee is twice
+	// the base fee of 1 gwei plus the tip
+	third := testTransferTx(2)
+	third.MaxPriorityFeePerGas = gwei(2)
+	third.MaxFeePerGas = gwei(4)
+
+	// the third transaction is stuck and replaced with 12.5% higher fees
+	replacement := testTransferTx(2)
+	replacement.MaxPriorityFeePerGas = big.NewInt(2250000001)
+	replacement.MaxFeePerGas = big.NewInt(4500000001)
+
+	expected := []string{
+		testSignedTx(t, first),
+		testSignedTx(t, second),
+		testSignedTx(t, third),
+		testSignedTx(t, replacement),
+	}
+
+	if len(node.txs) != len(expected) {
+		t.Fatalf("expected %d transactions but found %d", len(expected), len(node.txs))
+	}
+	for i := range expected {
+		if node.txs[i] != expected[i] {
+			t.Errorf("transaction %d: expected %s but found %s", i, expected[i], node.txs[i])
+		}
+	}
+}
+
+func TestSendTransactionReplaceRejected(t *testing.T) {
+	receiptPollInterval = time.Millisecond
+	defer func(d time.Duration) { replaceAfter = d }(replaceAfter)
+	defer func(d time.Duration) { receiptTimeout = d }(receiptTimeout)
+	replaceAfter = 10 * time.Millisecond
+	receiptTimeout = 200 * time.Millisecond
+
+	tests := []struct {
+		reject   string
+		expected string
+	}{
+		// the replacement is not sent again
+		{"replacement transaction underpriced", "not included after 200ms, its replacement failed: {\"code\":-32000,\"message\":\"replacement transaction underpriced\"}"},
+		// a transaction with the nonce was included, it is not replaced again
+		{"nonce too low", "not included after 200ms"},
+	}
+
+	for _, tt := range tests {
+		node := newTestNode()
+		node.stuck = map[int]bool{0: true}
+		node.reject = map[int]string{1: tt.reject}
+
+		env := object.NewEnvironment()
+		env.Set("endpoint", &object.String{Value: node.srv.URL})
+
+		input := "artifact (\"ERC20\")\nlet signer = Account(\"" + testPrivateKey + "\");\nERC20(0x3535353535353535353535353535353535353535).transfer(0x1111111111111111111111111111111111111111, 1).send()"
+		res := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		node.srv.Close()
+
+		expected := "transaction " + testTxHash(node.txs[0]) + " " + tt.expected
+		if errObj, ok := res.(*object.Error); !ok || errObj.Message != expected {
+			t.Fatalf("%s: expected error %q but found %v", tt.reject, expected, res.Inspect())
+		}
+		if node.sends != 2 {
+			t.Fatalf("%s: expected 2 transactions sent but found %d", tt.reject, node.sends)
+		}
+	}
+}
+
+func TestSendTransactionErrors(t *testing.T) {
+	receiptPollInterval = time.Millisecond
+
+	node := newTestNode()
+	defer node.srv.Close()
+	node.status = "0x0"
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send()`, "no account to send the transaction, set 'signer' or use send(account)"},
+		{`let signer = 1; t.transfer(0x1111111111111111111111111111111111111111, 1).send()`, "signer is not an account, found INTEGER"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send(Account(0x1111111111111111111111111111111111111111))`, "account 0x1111111111111111111111111111111111111111 has no key to send transactions"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send(1)`, "argument to `send` must be ACCOUNT or HASH, got INTEGER"},
+		{`t.transfer(1).send(Account("` + testPrivateKey + `"))`, "method transfer(address,uint256) expects 2 arguments, found 1"},
+		{`let h = {}; h["transfer"](1).send()`, "send is only supported on contract methods, got HASH"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send({}, 1)`, "the options must be the last argument of `send`, found INTEGER"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send({gas: -1})`, "option gas of `send` cannot be negative, got -1"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send({gas: "a"})`, "option gas of `send` must be INTEGER, got STRING"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send({other: 1})`, "unknown option other of `send`"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send({gasPrice: 1, tip: 1})`, "option gasPrice of `send` cannot be used with tip or maxFee"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send({tip: 2, maxFee: 1})`, "tip 2 is higher than maxFee 1"},
+		{`t.transfer(0x1111111111111111111111111111111111111111, 1).send(Account("` + testPrivateKey + `"), {tip: 1})`, `the chain does not support EIP-1559 fees, use gasPrice instead: {"code":-32601,"message":"method eth_feeHistory not found"}`},
+		{`Account("0x01")`, "private key must be 32 bytes, found 1"},
+		{`Account("./missing.json", "password")`, "failed to read keystore ./missing.json: open ./missing.json: no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Set("endpoint", &object.String{Value: node.srv.URL})
+
+		input := "artifact (\"ERC20\")\nlet t = ERC20(0x3535353535353535353535353535353535353535);\n" + tt.input
+		res := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+
+		errObj, ok := res.(*object.Error)
+		if !ok {
+			t.Errorf("%s: expected an error but found %v", tt.input, res)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%s: expected %q but found %q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+
+	// reverted transaction
+	env := object.NewEnvironment()
+	env.Set("endpoint", &object.String{Value: node.srv.URL})
+	env.Set("signer", testEval(`Account("`+testPrivateKey+`")`))
+
+	input := "artifact (\"ERC20\")\nERC20(0x3535353535353535353535353535353535353535).transfer(0x1111111111111111111111111111111111111111, 1).send()"
+	res := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+	if errObj, ok := res.(*object.Error); !ok || errObj.Message != "transaction "+testTxHash(node.txs[0])+" reverted" {
+		t.Fatalf("expected reverted transaction but found %v", res)
+	}
+}
@@ -1,23 +1,46 @@
 package lexer
 
 import (
+	"strings"
+
 	"github.com/umbracle/heura/heura/token"
 )
 
 type Lexer struct {
 	input        string
+	file         string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewAt returns a lexer for a piece of a source that starts at the given
+// position, i.e. an expression interpolated in a string
+func NewAt(pos token.Position, input string) *Lexer {
+	l := &Lexer{input: input, file: pos.File, line: pos.Line, column: pos.Column - 1}
 	l.readChar()
 	return l
 }
 
+// NewFile returns a lexer for the source of a file. The name of the file
+// is included in the position of the tokens.
+func NewFile(file, input string) *Lexer {
+	return NewAt(token.Position{File: file, Line: 1, Column: 1}, input)
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -28,9 +51,7 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
+func (l *Lexer) NextToken() (tok token.Token) {
 	l.skipWhitespace()
 
 	// skip single line comments
@@ -44,6 +65,9 @@ func (l *Lexer) NextToken() token.Token {
 		return l.NextToken()
 	}
 
+	pos := l.pos()
+	defer func() { tok.Pos = pos }()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -54,9 +78,9 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.readAssignOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.readAssignOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -66,13 +90,47 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.readAssignOperator(token.SLASH, token.SLASH_ASSIGN)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.readTwoCharToken(token.POWER)
+		} else {
+			tok = l.readAssignOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.readTwoCharToken(token.SHIFT_LEFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.readTwoCharToken(token.SHIFT_RIGHT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case '(':
@@ -82,7 +140,13 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '.':
-		tok = newToken(token.DOT, l.ch)
+		if l.peekChar() == '.' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.DOTDOT, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -95,7 +159,18 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COLON, l.ch)
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		if str, ok := l.readString(); ok {
+			tok.Literal = str
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: "\"" + str}
+		}
+	case '`':
+		tok.Type = token.RAW_STRING
+		if str, ok := l.readRawString(); ok {
+			tok.Literal = str
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: "`" + str}
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -111,6 +186,18 @@ func (l *Lexer) NextToken() token.Token {
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.INT
+			// a fractional part, the dot is not consumed in a range (1..5)
+			if l.ch == '.' && isDigit(l.peekChar()) {
+				l.readChar()
+				tok.Literal += "." + l.readNumber()
+				tok.Type = token.DECIMAL
+			}
+			// an exponent, i.e. 1e18
+			if (l.ch == 'e' || l.ch == 'E') && isDigit(l.peekChar()) {
+				exp := string(l.ch)
+				l.readChar()
+				tok.Literal += exp + l.readNumber()
+			}
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -121,6 +208,42 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// readAssignOperator returns the compound assignment token if the
+// operator is followed by '=', i.e. +=
+func (l *Lexer) readAssignOperator(op, assign token.TokenType) token.Token {
+	if l.peekChar() != '=' {
+		return newToken(op, l.ch)
+	}
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: assign, Literal: string(ch) + string(l.ch)}
+}
+
+// Line returns the given line of the source without the line break
+func (l *Lexer) Line(line int) string {
+	if line < 1 {
+		return ""
+	}
+
+	lines := strings.SplitN(l.input, "\n", line+1)
+	if len(lines) < line {
+		return ""
+	}
+	return strings.TrimRight(lines[line-1], "\r")
+}
+
+// pos returns the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{File: l.file, Line: l.line, Column: l.column}
+}
+
+// readTwoCharToken returns a token made of the current and the next char, i.e. &&
+func (l *Lexer) readTwoCharToken(t token.TokenType) token.Token {
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: t, Literal: string(ch) + string(l.ch)}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -138,9 +261,10 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[pos:l.position]
 }
 
+// readNumber reads digits, which can be separated with underscores (1_000)
 func (l *Lexer) readNumber() string {
 	position := l.position
-	for isDigit(l.ch) {
+	for isDigit(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -162,7 +286,7 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) skipMultilineComment() {
-	for {
+	for l.ch != 0 {
 		l.readChar()
 
 		if l.ch == '*' && l.peekChar() == '/' {
@@ -177,22 +301,84 @@ func (l *Lexer) skipMultilineComment() {
 }
 
 func (l *Lexer) skipComment() {
-	for l.ch != '\n' && l.ch != '\r' {
+	for l.ch != '\n' && l.ch != '\r' && l.ch != 0 {
 		l.readChar()
 	}
 	l.skipWhitespace()
 }
 
-func (l *Lexer) readString() string {
+// readString reads a string up to the closing quote. The escape sequences
+// and the interpolated expressions are kept as they are in the source. It
+// returns false if the string is not terminated.
+func (l *Lexer) readString() (string, bool) {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' {
-			break
+
+		switch l.ch {
+		case 0:
+			return l.input[position:l.position], false
+		case '"':
+			return l.input[position:l.position], true
+		case '\\':
+			l.readChar()
+			if l.ch == 0 {
+				return l.input[position:l.position], false
+			}
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				if !l.skipInterpolation() {
+					return l.input[position:l.position], false
+				}
+			}
 		}
 	}
+}
 
-	return l.input[position:l.position]
+// skipInterpolation skips an expression interpolated in a string up to
+// the closing brace, i.e. ${value}. The expression can have strings too.
+func (l *Lexer) skipInterpolation() bool {
+	depth := 1
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case 0:
+			return false
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return true
+			}
+		case '"':
+			if _, ok := l.readString(); !ok {
+				return false
+			}
+		case '`':
+			if _, ok := l.readRawString(); !ok {
+				return false
+			}
+		}
+	}
+}
+
+// readRawString reads a string between backticks, which can span multiple
+// lines and has no escape sequences
+func (l *Lexer) readRawString() (string, bool) {
+	position := l.position + 1
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case 0:
+			return l.input[position:l.position], false
+		case '`':
+			return l.input[position:l.position], true
+		}
+	}
 }
 
 func newToken(tokenType token.TokenType, ch byte) token.Token {
@@ -42,6 +42,136 @@ func TestTokens(t *testing.T) {
 				{token.BYTES, "0x111"},
 			},
 		},
+		{
+			"for i in 0..10 { break; continue; } while x {}",
+			[]expected{
+				{token.FOR, "for"},
+				{token.IDENT, "i"},
+				{token.IN, "in"},
+				{token.INT, "0"},
+				{token.DOTDOT, ".."},
+				{token.INT, "10"},
+				{token.LBRACE, "{"},
+				{token.BREAK, "break"},
+				{token.SEMICOLON, ";"},
+				{token.CONTINUE, "continue"},
+				{token.SEMICOLON, ";"},
+				{token.RBRACE, "}"},
+				{token.WHILE, "while"},
+				{token.IDENT, "x"},
+				{token.LBRACE, "{"},
+				{token.RBRACE, "}"},
+			},
+		},
+		{
+			"x += 1; x -= 1; x *= 2; x /= 2; x = 1 + -1 * 2 / 1",
+			[]expected{
+				{token.IDENT, "x"},
+				{token.PLUS_ASSIGN, "+="},
+				{token.INT, "1"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.MINUS_ASSIGN, "-="},
+				{token.INT, "1"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.ASTERISK_ASSIGN, "*="},
+				{token.INT, "2"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.SLASH_ASSIGN, "/="},
+				{token.INT, "2"},
+				{token.SEMICOLON, ";"},
+				{token.IDENT, "x"},
+				{token.ASSIGN, "="},
+				{token.INT, "1"},
+				{token.PLUS, "+"},
+				{token.MINUS, "-"},
+				{token.INT, "1"},
+				{token.ASTERISK, "*"},
+				{token.INT, "2"},
+				{token.SLASH, "/"},
+				{token.INT, "1"},
+			},
+		},
+		{
+			"\"a\\\"b\" `raw\n\\n` \"${h[\"}\"]} $x\" \"end",
+			[]expected{
+				{token.STRING, `a\"b`},
+				{token.RAW_STRING, "raw\n\\n"},
+				{token.STRING, `${h["}"]} $x`},
+				{token.ILLEGAL, `"end`},
+				{token.EOF, ""},
+			},
+		},
+		{
+			"`not closed",
+			[]expected{
+				{token.ILLEGAL, "`not closed"},
+				{token.EOF, ""},
+			},
+		},
+		{
+			"1_000_000 1e18 1.5E3 5 gwei 2ether 3e x",
+			[]expected{
+				{token.INT, "1_000_000"},
+				{token.INT, "1e18"},
+				{token.DECIMAL, "1.5E3"},
+				{token.INT, "5"},
+				{token.IDENT, "gwei"},
+				{token.INT, "2"},
+				{token.IDENT, "ether"},
+				{token.INT, "3"},
+				{token.IDENT, "e"},
+				{token.IDENT, "x"},
+			},
+		},
+		{
+			"1.5 0..3 10.25.x 2.",
+			[]expected{
+				{token.DECIMAL, "1.5"},
+				{token.INT, "0"},
+				{token.DOTDOT, ".."},
+				{token.INT, "3"},
+				{token.DECIMAL, "10.25"},
+				{token.DOT, "."},
+				{token.IDENT, "x"},
+				{token.INT, "2"},
+				{token.DOT, "."},
+			},
+		},
+		{
+			"a && b || c <= d >= e % f ** g & h | i ^ j << k >> l < m > n",
+			[]expected{
+				{token.IDENT, "a"},
+				{token.AND, "&&"},
+				{token.IDENT, "b"},
+				{token.OR, "||"},
+				{token.IDENT, "c"},
+				{token.LT_EQ, "<="},
+				{token.IDENT, "d"},
+				{token.GT_EQ, ">="},
+				{token.IDENT, "e"},
+				{token.PERCENT, "%"},
+				{token.IDENT, "f"},
+				{token.POWER, "**"},
+				{token.IDENT, "g"},
+				{token.AMPERSAND, "&"},
+				{token.IDENT, "h"},
+				{token.PIPE, "|"},
+				{token.IDENT, "i"},
+				{token.CARET, "^"},
+				{token.IDENT, "j"},
+				{token.SHIFT_LEFT, "<<"},
+				{token.IDENT, "k"},
+				{token.SHIFT_RIGHT, ">>"},
+				{token.IDENT, "l"},
+				{token.LT, "<"},
+				{token.IDENT, "m"},
+				{token.GT, ">"},
+				{token.IDENT, "n"},
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -189,3 +319,55 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := `let x = 5;
+// comment
+  fn(a) {
+	a >= 10
+}`
+
+	expected := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"fn", 3, 3},
+		{"(", 3, 5},
+		{"a", 3, 6},
+		{")", 3, 7},
+		{"{", 3, 9},
+		{"a", 4, 2},
+		{">=", 4, 4},
+		{"10", 4, 7},
+		{"}", 5, 1},
+		{"", 5, 2},
+	}
+
+	l := NewFile("script.hra", input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Literal != tt.literal {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.literal, tok.Literal)
+		}
+		if tok.Pos.File != "script.hra" || tok.Pos.Line != tt.line || tok.Pos.Column != tt.column {
+			t.Fatalf("tests[%d] - position of %q wrong. expected=%d:%d, got=%s", i, tt.literal, tt.line, tt.column, tok.Pos)
+		}
+	}
+}
+
+func TestLine(t *testing.T) {
+	l := New("let x = 1;\r\n\nfn() {}")
+
+	expected := []string{"", "let x = 1;", "", "fn() {}", ""}
+	for i, line := range expected {
+		if l.Line(i) != line {
+			t.Errorf("line %d wrong. expected=%q, got=%q", i, line, l.Line(i))
+		}
+	}
+}
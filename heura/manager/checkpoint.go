@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const checkpointFile = "checkpoints.json"
+
+// checkpointStore persists the last block processed by each listener
+type checkpointStore struct {
+	path string
+
+	lock   sync.Mutex
+	blocks map[string]uint64
+}
+
+// newCheckpointStore opens the checkpoints file in the state directory,
+// creating the directory if it does not exist
+func newCheckpointStore(dir string) (*checkpointStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &checkpointStore{
+		path:   filepath.Join(dir, checkpointFile),
+		blocks: map[string]uint64{},
+	}
+
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.blocks); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// get returns the last block processed by the listener
+func (c *checkpointStore) get(id string) (uint64, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	num, ok := c.blocks[id]
+	return num, ok
+}
+
+// ids returns the sorted ids of the listeners with a checkpoint
+func (c *checkpointStore) ids() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ids := []string{}
+	for id := range c.blocks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// set stores the last block processed by the listener. The file is written
+// to a temporary path first and then renamed so that it is never left half written
+func (c *checkpointStore) set(id string, num uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.blocks[id] = num
+
+	data, err := json.MarshalIndent(c.blocks, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCheckpointStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heura")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := newCheckpointStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.get("a"); ok {
+		t.Fatal("checkpoint should not exist")
+	}
+
+	if err := store.set("a", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.set("b", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.set("a", 11); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen the store
+	store, err = newCheckpointStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num, ok := store.get("a"); !ok || num != 11 {
+		t.Fatalf("expected 11 but found %d", num)
+	}
+	if num, ok := store.get("b"); !ok || num != 5 {
+		t.Fatalf("expected 5 but found %d", num)
+	}
+}
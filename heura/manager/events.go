@@ -2,6 +2,7 @@ package manager
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/umbracle/go-web3"
@@ -9,24 +10,158 @@ import (
 	"github.com/umbracle/go-web3/jsonrpc"
 
 	"github.com/umbracle/heura/heura/encoding"
+	"github.com/umbracle/heura/heura/ethereum"
 	"github.com/umbracle/heura/heura/evaluator"
 	"github.com/umbracle/heura/heura/object"
 )
 
-// EventManager is a wrapper to handle event logs
+// Config is the configuration of the event manager
+type Config struct {
+	// FromBlock is the first block to handle logs from. If not set,
+	// the listeners start from the current head
+	FromBlock *uint64
+
+	// ToBlock is the last block to handle logs from. If not set,
+	// the listeners keep following the head of the chain
+	ToBlock *uint64
+
+	// BatchSize is the number of blocks to query on each request
+	// while handling historical logs
+	BatchSize uint64
+
+	// PollInterval is the time between queries for new blocks
+	PollInterval time.Duration
+
+	// StateDir is the directory to store the last block processed by each
+	// listener. If set, the listeners resume from that block on restart
+	StateDir string
+
+	// OnFailure is the action taken when an event handler fails
+	OnFailure FailurePolicy
+
+	// MaxRetries is the number of times a failed handler is retried
+	// with the retry policy before stopping the script
+	MaxRetries int
+
+	// RetryBackoff is the time to wait before the first retry,
+	// it doubles on each attempt
+	RetryBackoff time.Duration
+
+	// ShutdownTimeout is the time to wait for the running handlers on shutdown
+	ShutdownTimeout time.Duration
+}
+
+// DefaultConfig returns the default configuration of the event manager
+func DefaultConfig() *Config {
+	return &Config{
+		BatchSize:       1000,
+		PollInterval:    3 * time.Second,
+		OnFailure:       FailureSkip,
+		MaxRetries:      5,
+		RetryBackoff:    time.Second,
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// errShutdown stops the processing of blocks once the manager is shutting down
+var errShutdown = fmt.Errorf("shutting down")
+
+// EventManager is a wrapper to handle event logs. A single block tracker
+// follows the chain for all the listeners, each new block is fetched once
+// and its logs are retrieved with one query that matches the events of
+// every listener. The logs are then dispatched to the matching listeners.
 type EventManager struct {
-	client  *jsonrpc.Client
-	env     *object.Environment
-	closeCh chan struct{}
+	provider    Provider
+	env         *object.Environment
+	config      *Config
+	checkpoints *checkpointStore
+	tracker     *blockTracker
+	closeCh     chan struct{}
+
+	// ws is the websocket client if the endpoint supports subscriptions
+	ws *wsClient
+
+	// wakeCh notifies the manager about new heads
+	wakeCh chan struct{}
+
+	lock      sync.Mutex
+	listeners []*listener
+
+	// recent are the logs of the blocks in the window of the tracker
+	// used to start the listeners that join after the first sync
+	recent map[web3.Hash]*blockLogs
+
+	startOnce sync.Once
+	doneCh    chan struct{}
+
+	// stoppedCh is closed once the manager is not running
+	stoppedCh chan struct{}
+	closeOnce sync.Once
+
+	// err is the handler failure that stopped the manager
+	err error
+}
+
+// NewEventManager creates a new event manager. If the endpoint is a websocket
+// (ws:// or wss://) the manager is notified of new blocks and of the logs
+// of the listeners with eth_subscribe, otherwise it polls the endpoint.
+func NewEventManager(endpoint string, env *object.Environment, config *Config) (*EventManager, error) {
+	if !isWebsocket(endpoint) {
+		client, err := jsonrpc.NewClient(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		return newEventManager(&httpProvider{client}, env, config)
+	}
+
+	client, err := newWsClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	e, err := newEventManager(client, env, config)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	e.ws = client
+
+	// catch up with the blocks missed while disconnected
+	client.onReconnect = e.wake
+	if err := client.SubscribeNewHeads(func(*web3.Block) { e.wake() }); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+func newEventManager(provider Provider, env *object.Environment, config *Config) (*EventManager, error) {
+	e := &EventManager{
+		provider:  provider,
+		config:    config,
+		closeCh:   make(chan struct{}),
+		env:       env,
+		tracker:   newBlockTracker(provider),
+		wakeCh:    make(chan struct{}, 1),
+		recent:    map[web3.Hash]*blockLogs{},
+		doneCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}
+
+	if config.StateDir != "" {
+		checkpoints, err := newCheckpointStore(config.StateDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the checkpoints: %v", err)
+		}
+		e.checkpoints = checkpoints
+	}
+	return e, nil
 }
 
-// NewEventManager creates a new event manager
-func NewEventManager(wsEndpoint string, env *object.Environment) *EventManager {
-	client, _ := jsonrpc.NewClient(wsEndpoint)
-	return &EventManager{
-		client:  client,
-		closeCh: make(chan struct{}),
-		env:     env,
+// wake notifies the manager to sync without waiting for the poll interval
+func (e *EventManager) wake() {
+	select {
+	case e.wakeCh <- struct{}{}:
+	default:
 	}
 }
 
@@ -37,68 +172,602 @@ func (e *EventManager) Listen(event *object.Event) error {
 		return fmt.Errorf("Contract %s not found", event.Contract)
 	}
 
-	eventAbi, ok := contract.ABI.Events[event.Method]
-	if !ok {
-		return fmt.Errorf("Event abi not found on contract")
+	eventAbi, err := ethereum.FindEvent(contract.ABI, event.Method, len(event.Parameters))
+	if err != nil {
+		return err
 	}
 
-	go e.listen(event, contract, eventAbi)
+	l := newListener(event, eventAbi, e.config, e.checkpoints)
+	l.closeCh = e.closeCh
+
+	if e.ws != nil {
+		// the logs of the subscription only trigger a sync, they are
+		// delivered once the tracker handles their block
+		if err := e.ws.SubscribeLogs(l.filter, func(*web3.Log) { e.wake() }); err != nil {
+			return err
+		}
+	}
+
+	e.lock.Lock()
+	e.listeners = append(e.listeners, l)
+	e.lock.Unlock()
 	return nil
 }
 
-func (e *EventManager) listen(event *object.Event, contract *object.Contract, eventAbi *abi.Event) {
-	sig := eventAbi.ID()
+// Start starts to follow the chain and deliver the logs to the listeners
+func (e *EventManager) Start() {
+	e.startOnce.Do(func() {
+		for _, id := range e.staleCheckpoints() {
+			fmt.Printf("warning: the checkpoint of %s does not match any event handler of the script\n", id)
+		}
+		go e.run()
+	})
+}
+
+// staleCheckpoints returns the ids of the stored checkpoints that do not
+// belong to any listener, i.e. the handler was removed or its event changed
+func (e *EventManager) staleCheckpoints() []string {
+	if e.checkpoints == nil {
+		return nil
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	stale := []string{}
+	for _, id := range e.checkpoints.ids() {
+		found := false
+		for _, l := range e.listeners {
+			if l.id == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			stale = append(stale, id)
+		}
+	}
+	return stale
+}
+
+// Done returns a channel that is closed once all the listeners have
+// handled the logs up to the last block of the configuration or a
+// handler failure stops the manager
+func (e *EventManager) Done() <-chan struct{} {
+	return e.doneCh
+}
+
+// Err returns the handler failure that stopped the manager, if any
+func (e *EventManager) Err() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.err
+}
 
-	filter := &web3.LogFilter{
-		Topics: []*web3.Hash{
-			&sig,
-		},
+// fail stops the manager if the error comes from a handler
+func (e *EventManager) fail(err error) bool {
+	if _, ok := err.(*handlerError); !ok {
+		return false
 	}
+	e.lock.Lock()
+	e.err = err
+	e.lock.Unlock()
+	return true
+}
+
+func (e *EventManager) run() {
+	defer close(e.stoppedCh)
 
-	var lastBlock *web3.Block
 	for {
 		select {
 		case <-e.closeCh:
 			return
 
-		case <-time.After(3 * time.Second):
-			block, err := e.client.Eth().GetBlockByNumber(web3.Latest, false)
-			if err != nil {
+		case <-e.wakeCh:
+		case <-time.After(e.config.PollInterval):
+		}
+
+		if e.sync() {
+			close(e.doneCh)
+			return
+		}
+	}
+}
+
+// sync processes the blocks produced since the last call and returns
+// true once all the listeners have reached their last block or if a
+// handler failure stops the manager
+func (e *EventManager) sync() bool {
+	e.lock.Lock()
+	listeners := append([]*listener{}, e.listeners...)
+	e.lock.Unlock()
+
+	if e.tracker.lastBlock() == nil {
+		// start early enough to have the confirmed blocks of every listener
+		for _, l := range listeners {
+			if l.confirmations > e.tracker.startDepth {
+				e.tracker.startDepth = l.confirmations
+			}
+		}
+		if depth := int(e.tracker.startDepth) + 1; depth > e.tracker.maxDepth {
+			e.tracker.maxDepth = depth
+		}
+	}
+
+	filter := e.buildFilter(listeners)
+	err := e.tracker.sync(func(update *blockUpdate) error {
+		if e.closing() {
+			return errShutdown
+		}
+		return e.handleUpdate(listeners, filter, update)
+	})
+	if err != nil && err != errShutdown {
+		if e.fail(err) {
+			return true
+		}
+		fmt.Println(err)
+	}
+
+	last := e.tracker.lastBlock()
+	if last == nil {
+		return false
+	}
+
+	done := true
+	for _, l := range listeners {
+		if e.closing() {
+			return false
+		}
+		if l.done {
+			continue
+		}
+		if !l.live {
+			if err := l.join(e.provider, e.tracker.window, e.recent, e.config.BatchSize); err != nil && err != errShutdown {
+				if e.fail(err) {
+					return true
+				}
 				fmt.Println(err)
-				continue
 			}
+		}
+		if l.live {
+			if err := l.flush(last.Number); err != nil {
+				e.fail(err)
+				return true
+			}
+		}
+		if !l.done {
+			done = false
+		}
+	}
+	return done && len(listeners) != 0
+}
+
+// buildFilter returns the filter that matches the logs of all the
+// active listeners or nil if there are none
+func (e *EventManager) buildFilter(listeners []*listener) *LogFilter {
+	filters := []*LogFilter{}
+	for _, l := range listeners {
+		if !l.done {
+			filters = append(filters, l.filter)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return mergeFilters(filters)
+}
+
+// handleUpdate retrieves the logs of a new block with a single query
+// and dispatches the update to the listeners
+func (e *EventManager) handleUpdate(listeners []*listener, filter *LogFilter, update *blockUpdate) error {
+	block := update.Added
+
+	var logs []*web3.Log
+	if filter != nil {
+		query := *filter
+		query.BlockHash = &block.Hash
+
+		var err error
+		if logs, err = e.provider.GetLogs(&query); err != nil {
+			return err
+		}
+	}
 
-			if lastBlock != nil && lastBlock.Hash == block.Hash {
-				continue
+	for _, l := range listeners {
+		if l.live && !l.done {
+			if err := l.update(update, logs); err != nil {
+				return err
 			}
+		}
+	}
+
+	e.recent[block.Hash] = &blockLogs{block: block, logs: logs, filter: filter}
+	for hash, b := range e.recent {
+		if b.block.Number+uint64(e.tracker.maxDepth) <= block.Number {
+			delete(e.recent, hash)
+		}
+	}
+	return nil
+}
+
+// blockLogs are the logs of a block
+type blockLogs struct {
+	block *web3.Block
+	logs  []*web3.Log
+
+	// filter is the query used to retrieve the logs
+	filter *LogFilter
+}
+
+// listener delivers the logs of a single on statement
+type listener struct {
+	event    *object.Event
+	eventAbi *abi.Event
+	filter   *LogFilter
+
+	// confirmations is the number of blocks the head has to be ahead of a block to deliver it
+	confirmations uint64
+
+	// start is the first block to deliver, if not set the listener
+	// starts from the head of the chain minus the confirmations
+	start *uint64
+
+	// next is the next historical block to query while joining
+	next uint64
 
+	// endBlock is the last block to deliver, if any
+	endBlock *uint64
+
+	// live is set once the listener receives the updates of the tracker
+	live bool
+	done bool
+
+	// pending are the blocks waiting for confirmations, oldest first
+	pending []*blockLogs
+
+	// delivered are the logs applied for the blocks in the reorg window
+	delivered map[web3.Hash]*blockLogs
+	maxDepth  uint64
+
+	onFailure    FailurePolicy
+	maxRetries   int
+	retryBackoff time.Duration
+
+	// closeCh interrupts the retries of a failed handler
+	closeCh chan struct{}
+
+	id          string
+	checkpoints *checkpointStore
+}
+
+func newListener(event *object.Event, eventAbi *abi.Event, config *Config, checkpoints *checkpointStore) *listener {
+	l := &listener{
+		event:         event,
+		eventAbi:      eventAbi,
+		filter:        buildLogFilter(event, eventAbi),
+		confirmations: event.Confirmations,
+		endBlock:      config.ToBlock,
+		delivered:     map[web3.Hash]*blockLogs{},
+		maxDepth:      defaultMaxReorgDepth,
+		onFailure:     config.OnFailure,
+		maxRetries:    config.MaxRetries,
+		retryBackoff:  config.RetryBackoff,
+		id:            event.ID,
+		checkpoints:   checkpoints,
+	}
+	if config.FromBlock != nil {
+		start := *config.FromBlock
+		l.start = &start
+	}
+
+	// resume after the last processed block
+	if checkpoints != nil {
+		if num, ok := checkpoints.get(l.id); ok {
+			start := num + 1
+			l.start = &start
+		}
+	}
+	if l.start != nil {
+		l.next = *l.start
+	}
+	return l
+}
+
+// join catches up with the tracker. The historical blocks before the window
+// of the tracker are queried with range queries of batchSize blocks, the
+// blocks in the window are taken from the logs already retrieved.
+func (l *listener) join(provider Provider, window []*web3.Block, recent map[web3.Hash]*blockLogs, batchSize uint64) error {
+	last := window[len(window)-1]
+	if l.start == nil {
+		start := uint64(0)
+		if last.Number > l.confirmations {
+			start = last.Number - l.confirmations
+		}
+		l.start = &start
+		l.next = start
+	}
+
+	first := window[0].Number
+	filter := *l.filter
+
+	for l.next < first && !l.isEnd(l.next) {
+		if l.closing() {
+			return errShutdown
+		}
+
+		to := l.next + batchSize - 1
+		if to >= first {
+			to = first - 1
+		}
+		if l.endBlock != nil && to > *l.endBlock {
+			to = *l.endBlock
+		}
+
+		filter.From = &l.next
+		filter.To = &to
+
+		logs, err := provider.GetLogs(&filter)
+		if err != nil {
+			return err
+		}
+		for _, log := range logs {
+			if err := l.apply(log); err != nil {
+				return err
+			}
+		}
+		l.next = to + 1
+		l.checkpoint(to)
+	}
+
+	for _, block := range window {
+		b, ok := recent[block.Hash]
+		if !ok || b.filter == nil || !b.filter.covers(l.filter) {
+			// the listener was added after the block was processed
+			filter.From, filter.To = nil, nil
 			filter.BlockHash = &block.Hash
-			logs, err := e.client.Eth().GetLogs(filter)
+
+			logs, err := provider.GetLogs(&filter)
 			if err != nil {
-				fmt.Println(err)
-				continue
+				return err
 			}
+			b = &blockLogs{block: block, logs: logs}
+		}
+		if err := l.update(&blockUpdate{Added: block}, b.logs); err != nil {
+			return err
+		}
+	}
 
-			for _, log := range logs {
-				res, err := abi.ParseLog(eventAbi.Inputs, log)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
-				objs, err := encoding.ArgumentsToObjects(eventAbi.Inputs, res)
-				if err != nil {
-					fmt.Println(err)
-					continue
-				}
+	l.live = true
+	if l.isEnd(l.next) && len(l.pending) == 0 {
+		l.done = true
+	}
+	return nil
+}
 
-				evaluator.ApplyEvent(*event, objs, log)
+// closing returns true once the manager is shutting down
+func (l *listener) closing() bool {
+	select {
+	case <-l.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// isEnd returns true if the block is after the end block
+func (l *listener) isEnd(num uint64) bool {
+	return l.endBlock != nil && num > *l.endBlock
+}
+
+// update queues the logs of a new block that match the filter of the
+// listener. If the update comes from a reorg the removed blocks still
+// pending are discarded and the logs of the delivered ones are applied
+// again in reverse order with the removed flag set so that the handlers
+// can undo their effects.
+func (l *listener) update(update *blockUpdate, logs []*web3.Log) error {
+	for _, removed := range update.Removed {
+		found := false
+		for i, b := range l.pending {
+			if b.block.Hash == removed.Hash {
+				l.pending = append(l.pending[:i], l.pending[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		d, ok := l.delivered[removed.Hash]
+		if !ok {
+			continue
+		}
+		for i := len(d.logs) - 1; i >= 0; i-- {
+			log := *d.logs[i]
+			log.Removed = true
+			if err := l.apply(&log); err != nil {
+				return err
 			}
-			lastBlock = block
 		}
+		delete(l.delivered, removed.Hash)
 	}
+
+	block := update.Added
+	if block.Number < *l.start || l.isEnd(block.Number) {
+		return nil
+	}
+
+	matched := []*web3.Log{}
+	for _, log := range logs {
+		if l.filter.Match(log) {
+			matched = append(matched, log)
+		}
+	}
+	l.pending = append(l.pending, &blockLogs{block: block, logs: matched})
+	return nil
+}
+
+// flush applies the logs of the pending blocks with enough confirmations
+func (l *listener) flush(head uint64) error {
+	for len(l.pending) != 0 && !l.closing() {
+		b := l.pending[0]
+		if b.block.Number+l.confirmations > head {
+			return nil
+		}
+
+		for _, log := range b.logs {
+			if err := l.apply(log); err != nil {
+				return err
+			}
+		}
+		l.pending = l.pending[1:]
+		l.delivered[b.block.Hash] = b
+
+		// prune the blocks that cannot be reorged anymore
+		for hash, d := range l.delivered {
+			if d.block.Number+l.maxDepth <= b.block.Number {
+				delete(l.delivered, hash)
+			}
+		}
+
+		l.checkpoint(b.block.Number)
+		if l.endBlock != nil && b.block.Number >= *l.endBlock {
+			l.done = true
+			return nil
+		}
+	}
+	return nil
 }
 
-// Shutdown closes the manager and all the event listeners
-func (e *EventManager) Shutdown() {
-	close(e.closeCh)
+// checkpoint stores the last processed block. The logs have already been
+// applied, a failure is reported but the block is not processed again.
+func (l *listener) checkpoint(num uint64) {
+	if l.checkpoints == nil {
+		return
+	}
+	if err := l.checkpoints.set(l.id, num); err != nil {
+		fmt.Printf("failed to store the checkpoint of %s: %v\n", l.id, err)
+	}
+}
+
+// apply runs the handler with the log. A handler failure is reported and
+// handled with the failure policy, it returns an error if the failure
+// has to stop the script. Logs that cannot be decoded are skipped.
+func (l *listener) apply(log *web3.Log) error {
+	res, err := abi.ParseLog(l.eventAbi.Inputs, log)
+	if err != nil {
+		fmt.Printf("failed to decode log of %s %s: %v\n", l.id, logContext(log), err)
+		return nil
+	}
+	objs, err := encoding.ArgumentsToObjects(l.eventAbi.Inputs, res)
+	if err != nil {
+		fmt.Printf("failed to decode log of %s %s: %v\n", l.id, logContext(log), err)
+		return nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		_, err := evaluator.ApplyEvent(*l.event, objs, log)
+		if err == nil {
+			return nil
+		}
+		herr := &handlerError{id: l.id, log: log, err: err}
+		fmt.Println(herr)
+
+		switch l.onFailure {
+		case FailureSkip:
+			return nil
+
+		case FailureStop:
+			return herr
+		}
+
+		if attempt >= l.maxRetries {
+			herr.err = fmt.Errorf("%v (after %d retries)", err, attempt)
+			return herr
+		}
+		select {
+		case <-time.After(retryBackoff(l.retryBackoff, attempt)):
+		case <-l.closeCh:
+			return herr
+		}
+	}
+}
+
+// buildLogFilter returns the log filter for the event with the signature as
+// the first topic followed by the indexed parameter filters
+func buildLogFilter(event *object.Event, eventAbi *abi.Event) *LogFilter {
+	filter := &LogFilter{
+		Topics: [][]web3.Hash{{eventAbi.ID()}},
+	}
+	for _, topic := range event.Topics {
+		if topic == nil {
+			filter.Topics = append(filter.Topics, nil)
+		} else {
+			filter.Topics = append(filter.Topics, []web3.Hash{*topic})
+		}
+	}
+	if event.Address != nil {
+		filter.Address = []web3.Address{*event.Address}
+	}
+	return filter
+}
+
+// closing returns true once the shutdown has started
+func (e *EventManager) closing() bool {
+	select {
+	case <-e.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops fetching new blocks and waits up to the shutdown timeout
+// for the running handlers to finish. The checkpoints are stored after each
+// block so they are up to date once the handlers stop. It returns an error
+// if the handlers are still running after the timeout.
+func (e *EventManager) Shutdown() error {
+	e.closeOnce.Do(func() {
+		close(e.closeCh)
+
+		// the manager was never started
+		e.startOnce.Do(func() {
+			close(e.stoppedCh)
+		})
+		if e.ws != nil {
+			e.ws.Close()
+		}
+	})
+
+	select {
+	case <-e.stoppedCh:
+		return nil
+	case <-time.After(e.config.ShutdownTimeout):
+		return fmt.Errorf("timeout waiting for the event handlers to finish")
+	}
+}
+
+// httpProvider is the Provider for http endpoints
+type httpProvider struct {
+	client *jsonrpc.Client
+}
+
+// BlockNumber implements the Provider interface
+func (p *httpProvider) BlockNumber() (uint64, error) {
+	return p.client.Eth().BlockNumber()
+}
+
+// GetBlockByNumber implements the Provider interface
+func (p *httpProvider) GetBlockByNumber(i web3.BlockNumber, full bool) (*web3.Block, error) {
+	return p.client.Eth().GetBlockByNumber(i, full)
+}
+
+// GetLogs implements the Provider interface
+func (p *httpProvider) GetLogs(filter *LogFilter) ([]*web3.Log, error) {
+	var logs []*web3.Log
+	if err := p.client.Call("eth_getLogs", &logs, filter); err != nil {
+		return nil, err
+	}
+	return logs, nil
 }
@@ -0,0 +1,472 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/umbracle/go-web3"
+	"github.com/umbracle/heura/heura/evaluator"
+	"github.com/umbracle/heura/heura/lexer"
+	"github.com/umbracle/heura/heura/object"
+	"github.com/umbracle/heura/heura/parser"
+)
+
+const testScript = `
+artifact ("ERC20")
+
+on ERC20.Transfer(from, to, value) {
+	record(value, this.removed)
+}
+`
+
+// testRecorder collects the calls to the record builtin of the test script
+type testRecorder struct {
+	lock   sync.Mutex
+	calls  []string
+	notify chan struct{}
+}
+
+func (r *testRecorder) Fn(args ...object.Object) object.Object {
+	r.lock.Lock()
+	r.calls = append(r.calls, fmt.Sprintf("%s %s", args[0].Inspect(), args[1].Inspect()))
+	r.lock.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+	return &object.Null{}
+}
+
+func (r *testRecorder) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return fmt.Sprint(r.calls)
+}
+
+func testEnv(t *testing.T, script string) (*object.Environment, *testRecorder) {
+	env := object.NewEnvironment()
+	recorder := &testRecorder{notify: make(chan struct{}, 1)}
+	env.AddBuiltin("record", &object.Builtin{Fn: recorder.Fn})
+
+	p := parser.New(lexer.New(script))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatal(p.Errors())
+	}
+	if res := evaluator.Eval(program, env); res != nil && res.Type() == object.ERROR_OBJ {
+		t.Fatal(res.Inspect())
+	}
+	return env, recorder
+}
+
+func testManager(t *testing.T, chain *testChain, script string, config *Config) (*EventManager, *testRecorder) {
+	env, recorder := testEnv(t, script)
+
+	e, err := newEventManager(chain, env, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range env.GetOnStatements() {
+		if err := e.Listen(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e, recorder
+}
+
+var (
+	// keccak256("Transfer(address,address,uint256)")
+	transferSig = web3.Hash{0xdd, 0xf2, 0x52, 0xad, 0x1b, 0xe2, 0xc8, 0x9b, 0x69, 0xc2, 0xb0, 0x68, 0xfc, 0x37, 0x8d, 0xaa, 0x95, 0x2b, 0xa7, 0xf1, 0x63, 0xc4, 0xa1, 0x16, 0x28, 0xf5, 0x5a, 0x4d, 0xf5, 0x23, 0xb3, 0xef}
+
+	// keccak256("Approval(address,address,uint256)")
+	approvalSig = web3.Hash{0x8c, 0x5b, 0xe1, 0xe5, 0xeb, 0xec, 0x7d, 0x5b, 0xd1, 0x4f, 0x71, 0x42, 0x7d, 0x1e, 0x84, 0xf3, 0xdd, 0x03, 0x14, 0xc0, 0xf7, 0xb2, 0x29, 0x1e, 0x5b, 0x20, 0x0a, 0xc8, 0xc7, 0xc3, 0xb9, 0x25}
+)
+
+// addTransfer adds a Transfer log to the head of the chain
+func (c *testChain) addTransfer(value int64) {
+	c.addLog(transferSig, value)
+}
+
+// addLog adds a log with two indexed parameters and a value to the head of the chain
+func (c *testChain) addLog(sig web3.Hash, value int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	head := c.blocks[len(c.blocks)-1]
+
+	data := make([]byte, 32)
+	big.NewInt(value).FillBytes(data)
+
+	c.logs[head.Hash] = append(c.logs[head.Hash], &web3.Log{
+		BlockNumber: head.Number,
+		BlockHash:   head.Hash,
+		Topics:      []web3.Hash{sig, {}, {}},
+		Data:        data,
+	})
+}
+
+func TestEventManagerReorg(t *testing.T) {
+	chain := newTestChain()
+	e, recorder := testManager(t, chain, testScript, DefaultConfig())
+	e.sync()
+
+	chain.addBlocks(1)
+	chain.addTransfer(1)
+	chain.addBlocks(1)
+	chain.addTransfer(2)
+	e.sync()
+
+	// replace the last two blocks
+	chain.reorg(2, 2, 1)
+	chain.addTransfer(3)
+	e.sync()
+
+	expected := "[1 false 2 false 2 true 1 true 3 false]"
+	if recorder.String() != expected {
+		t.Fatalf("expected %s but found %v", expected, recorder.calls)
+	}
+}
+
+func TestEventManagerBackfill(t *testing.T) {
+	chain := newTestChain()
+	for i := int64(1); i <= 5; i++ {
+		chain.addBlocks(1)
+		chain.addTransfer(i)
+	}
+
+	from, to := uint64(2), uint64(7)
+
+	config := DefaultConfig()
+	config.FromBlock = &from
+	config.ToBlock = &to
+	config.BatchSize = 2
+
+	e, recorder := testManager(t, chain, testScript, config)
+
+	// backfill the blocks 2 to 5 and follow the head
+	if e.sync() {
+		t.Fatal("it should not be done")
+	}
+	if chain.queries != 2 {
+		t.Fatalf("expected 2 queries but found %d", chain.queries)
+	}
+
+	for i := int64(6); i <= 8; i++ {
+		chain.addBlocks(1)
+		chain.addTransfer(i)
+	}
+
+	if !e.sync() {
+		t.Fatal("it should be done")
+	}
+
+	expected := "[2 false 3 false 4 false 5 false 6 false 7 false]"
+	if recorder.String() != expected {
+		t.Fatalf("expected %s but found %v", expected, recorder.calls)
+	}
+}
+
+func TestEventManagerCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "heura")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig()
+	config.StateDir = dir
+
+	chain := newTestChain()
+	e, recorder := testManager(t, chain, testScript, config)
+
+	chain.addBlocks(1)
+	chain.addTransfer(1)
+	e.sync()
+
+	// blocks produced while the script is stopped
+	for i := int64(2); i <= 4; i++ {
+		chain.addBlocks(1)
+		chain.addTransfer(i)
+	}
+
+	// the handler keeps its checkpoint when the script is edited around it
+	edited := strings.Replace(testScript, "on ERC20", "let a = 1;\nlet b = 2;\n\non ERC20", 1)
+	e, recorder2 := testManager(t, chain, edited, config)
+	e.sync()
+
+	if recorder.String() != "[1 false]" {
+		t.Fatalf("bad calls %v", recorder.calls)
+	}
+	if recorder2.String() != "[2 false 3 false 4 false]" {
+		t.Fatalf("bad calls %v", recorder2.calls)
+	}
+	if stale := e.staleCheckpoints(); len(stale) != 0 {
+		t.Fatalf("unexpected stale checkpoints %v", stale)
+	}
+
+	// the checkpoint of a removed handler matches no listener
+	e, _ = testManager(t, chain, strings.Replace(testScript, "Transfer", "Approval", 1), config)
+	if stale := fmt.Sprint(e.staleCheckpoints()); stale != "[ERC20.Transfer(address,address,uint256)]" {
+		t.Fatalf("bad stale checkpoints %s", stale)
+	}
+}
+
+func TestEventManagerWebsocket(t *testing.T) {
+	chain := newTestChain()
+	srv := newTestWsServer(chain)
+	defer srv.srv.Close()
+
+	env, recorder := testEnv(t, testScript)
+
+	// only the subscriptions wake up the listener
+	config := DefaultConfig()
+	config.PollInterval = time.Hour
+
+	e, err := NewEventManager(srv.url(), env, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Shutdown()
+
+	srv.expectSubscribe(t, `["newHeads"]`)
+	if err := e.Listen(env.GetOnStatements()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := json.Marshal(e.listeners[0].filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logsParams := `["logs",` + string(filter) + `]`
+	srv.expectSubscribe(t, logsParams)
+	e.Start()
+
+	for i := int64(1); i <= 4; i++ {
+		chain.addBlocks(1)
+		chain.addTransfer(i)
+
+		// the logs are delivered once the tracker handles their block
+		// whichever subscription notifies first
+		if i%2 == 0 {
+			srv.notify(logsParams, &web3.Log{BlockNumber: uint64(i)})
+		} else {
+			srv.notify(`["newHeads"]`, chain.head())
+		}
+
+		select {
+		case <-recorder.notify:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+
+	if recorder.String() != "[1 false 2 false 3 false 4 false]" {
+		t.Fatalf("bad calls %s", recorder.String())
+	}
+}
+
+func TestEventManagerSharedQueries(t *testing.T) {
+	script := `
+	artifact ("ERC20")
+
+	on ERC20.Transfer(from, to, value) {
+		record(value, this.removed)
+	}
+
+	on ERC20.Approval(owner, spender, value) confirmations 2 {
+		record("approval", value)
+	}
+	`
+
+	chain := newTestChain()
+	chain.addBlocks(2)
+
+	e, recorder := testManager(t, chain, script, DefaultConfig())
+
+	// starts two blocks behind the head for the confirmations
+	e.sync()
+
+	chain.addBlocks(1)
+	chain.addTransfer(1)
+	chain.addLog(approvalSig, 10)
+	chain.addBlocks(1)
+	chain.addTransfer(2)
+	chain.addLog(approvalSig, 20)
+	e.sync()
+
+	// the approval of block 4 is dropped before it is confirmed
+	chain.reorg(1, 2, 1)
+	chain.addTransfer(3)
+	e.sync()
+
+	if chain.blockQueries != 7 {
+		t.Fatalf("expected one query per block but found %d", chain.blockQueries)
+	}
+	if chain.queries != 0 {
+		t.Fatalf("expected no range queries but found %d", chain.queries)
+	}
+
+	// the order between handlers is not defined
+	transfers, approvals := []string{}, []string{}
+	for _, call := range recorder.calls {
+		if strings.HasPrefix(call, "approval") {
+			approvals = append(approvals, call)
+		} else {
+			transfers = append(transfers, call)
+		}
+	}
+	if fmt.Sprint(transfers) != "[1 false 2 false 2 true 3 false]" {
+		t.Fatalf("bad transfers %v", transfers)
+	}
+	if fmt.Sprint(approvals) != "[approval 10]" {
+		t.Fatalf("bad approvals %v", approvals)
+	}
+}
+
+func TestEventManagerFailurePolicy(t *testing.T) {
+	script := `
+	artifact ("ERC20")
+
+	on ERC20.Transfer(from, to, value) {
+		if (value == 2) {
+			fail();
+		}
+		record(value, this.removed)
+	}
+	`
+
+	cases := []struct {
+		policy   FailurePolicy
+		failures int
+		stopped  bool
+		calls    string
+	}{
+		{FailureSkip, 1, false, "[1 false 3 false]"},
+		{FailureRetry, 2, false, "[1 false 2 false 3 false]"},
+		{FailureRetry, 10, true, "[1 false]"},
+		{FailureStop, 1, true, "[1 false]"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.policy.String(), func(t *testing.T) {
+			chain := newTestChain()
+
+			config := DefaultConfig()
+			config.OnFailure = c.policy
+			config.MaxRetries = 3
+			config.RetryBackoff = time.Millisecond
+
+			e, recorder := testManager(t, chain, script, config)
+
+			// fail is an error until it is called the given number of times
+			failures := c.failures
+			e.env.AddBuiltin("fail", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+				if failures == 0 {
+					return &object.Null{}
+				}
+				failures--
+				return &object.Error{Message: "failed"}
+			}})
+
+			e.sync()
+			for i := int64(1); i <= 3; i++ {
+				chain.addBlocks(1)
+				chain.addTransfer(i)
+			}
+
+			if stopped := e.sync(); stopped != c.stopped {
+				t.Fatalf("expected stopped %v", c.stopped)
+			}
+			if (e.Err() != nil) != c.stopped {
+				t.Fatalf("unexpected error %v", e.Err())
+			}
+			if recorder.String() != c.calls {
+				t.Fatalf("expected %s but found %s", c.calls, recorder.String())
+			}
+		})
+	}
+}
+
+func TestEventManagerShutdown(t *testing.T) {
+	script := `
+	artifact ("ERC20")
+
+	on ERC20.Transfer(from, to, value) {
+		wait();
+		record(value, this.removed)
+	}
+	`
+
+	for _, timeout := range []bool{false, true} {
+		chain := newTestChain()
+
+		config := DefaultConfig()
+		config.PollInterval = time.Hour
+		if timeout {
+			config.ShutdownTimeout = 10 * time.Millisecond
+		}
+
+		e, recorder := testManager(t, chain, script, config)
+
+		// wait blocks the handler until it is released
+		runningCh, releaseCh := make(chan struct{}), make(chan struct{})
+		e.env.AddBuiltin("wait", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			runningCh <- struct{}{}
+			<-releaseCh
+			return &object.Null{}
+		}})
+
+		e.sync()
+		e.Start()
+
+		chain.addBlocks(1)
+		chain.addTransfer(1)
+		chain.addBlocks(1)
+		chain.addTransfer(2)
+		e.wake()
+
+		select {
+		case <-runningCh:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- e.Shutdown()
+		}()
+
+		if timeout {
+			if err := <-errCh; err == nil {
+				t.Fatal("it should timeout")
+			}
+			close(releaseCh)
+			continue
+		}
+
+		select {
+		case <-errCh:
+			t.Fatal("it should wait for the running handler")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(releaseCh)
+
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+
+		// the block of the running handler is finished but no other block is handled
+		if recorder.String() != "[1 false]" {
+			t.Fatalf("bad calls %s", recorder.String())
+		}
+	}
+}
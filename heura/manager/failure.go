@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/umbracle/go-web3"
+)
+
+// maxRetryBackoff is the maximum time to wait between retries of a handler
+var maxRetryBackoff = time.Minute
+
+// FailurePolicy is the action taken when an event handler fails
+type FailurePolicy int
+
+const (
+	// FailureSkip reports the error and continues with the next log
+	FailureSkip FailurePolicy = iota
+
+	// FailureRetry runs the handler again with an exponential backoff
+	// and stops the script if it keeps failing
+	FailureRetry
+
+	// FailureStop stops the script. The block of the failed log is
+	// not checkpointed so it is handled again on restart.
+	FailureStop
+)
+
+// ParseFailurePolicy returns the failure policy with the given name
+func ParseFailurePolicy(name string) (FailurePolicy, error) {
+	switch name {
+	case "skip":
+		return FailureSkip, nil
+	case "retry":
+		return FailureRetry, nil
+	case "stop":
+		return FailureStop, nil
+	}
+	return 0, fmt.Errorf("unknown failure policy '%s', expected skip, retry or stop", name)
+}
+
+func (f FailurePolicy) String() string {
+	switch f {
+	case FailureSkip:
+		return "skip"
+	case FailureRetry:
+		return "retry"
+	case FailureStop:
+		return "stop"
+	}
+	return fmt.Sprintf("FailurePolicy(%d)", int(f))
+}
+
+// handlerError is the error of an event handler that stops the script
+type handlerError struct {
+	id  string
+	log *web3.Log
+	err error
+}
+
+func (h *handlerError) Error() string {
+	return fmt.Sprintf("handler %s failed %s: %v", h.id, logContext(h.log), h.err)
+}
+
+// logContext describes the position of the log in the chain
+func logContext(log *web3.Log) string {
+	return fmt.Sprintf("at block %d (hash %s, tx %s, log index %d)", log.BlockNumber, log.BlockHash, log.TransactionHash, log.LogIndex)
+}
+
+// retryBackoff returns the time to wait before the given retry attempt
+func retryBackoff(base time.Duration, attempt int) time.Duration {
+	backoff := base << uint(attempt)
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		backoff = maxRetryBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/umbracle/go-web3"
+)
+
+// LogFilter is an eth_getLogs filter in which the address and each
+// topic position can match any of several values
+type LogFilter struct {
+	// Address are the contracts emitting the logs, empty matches any contract
+	Address []web3.Address
+
+	// Topics are the values for each topic position, an empty
+	// position matches any value
+	Topics [][]web3.Hash
+
+	BlockHash *web3.Hash
+	From      *uint64
+	To        *uint64
+}
+
+// Match returns true if the log matches the address and topics of the filter
+func (f *LogFilter) Match(log *web3.Log) bool {
+	if len(f.Address) != 0 && !containsAddress(f.Address, log.Address) {
+		return false
+	}
+
+	for indx, values := range f.Topics {
+		if len(values) == 0 {
+			continue
+		}
+		if indx >= len(log.Topics) || !containsHash(values, log.Topics[indx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (f *LogFilter) MarshalJSON() ([]byte, error) {
+	obj := map[string]interface{}{}
+
+	if len(f.Address) == 1 {
+		obj["address"] = f.Address[0]
+	} else if len(f.Address) > 1 {
+		obj["address"] = f.Address
+	}
+
+	topics := []interface{}{}
+	for _, values := range f.Topics {
+		switch len(values) {
+		case 0:
+			topics = append(topics, nil)
+		case 1:
+			topics = append(topics, values[0])
+		default:
+			topics = append(topics, values)
+		}
+	}
+	obj["topics"] = topics
+
+	if f.BlockHash != nil {
+		obj["blockHash"] = f.BlockHash
+	}
+	if f.From != nil {
+		obj["fromBlock"] = fmt.Sprintf("0x%x", *f.From)
+	}
+	if f.To != nil {
+		obj["toBlock"] = fmt.Sprintf("0x%x", *f.To)
+	}
+	return json.Marshal(obj)
+}
+
+// mergeFilters returns a filter that matches the logs of any of the filters
+// on the address and the event signature (the first topic)
+func mergeFilters(filters []*LogFilter) *LogFilter {
+	merged := &LogFilter{
+		Topics: [][]web3.Hash{{}},
+	}
+
+	anyAddress := false
+
+	for _, f := range filters {
+		if len(f.Address) == 0 {
+			anyAddress = true
+		}
+		for _, addr := range f.Address {
+			if !containsAddress(merged.Address, addr) {
+				merged.Address = append(merged.Address, addr)
+			}
+		}
+		if len(f.Topics) == 0 || len(f.Topics[0]) == 0 {
+			merged.Topics = nil
+			continue
+		}
+		if merged.Topics == nil {
+			continue
+		}
+		for _, sig := range f.Topics[0] {
+			if !containsHash(merged.Topics[0], sig) {
+				merged.Topics[0] = append(merged.Topics[0], sig)
+			}
+		}
+	}
+
+	if anyAddress {
+		merged.Address = nil
+	}
+	return merged
+}
+
+// covers returns true if all the logs that match the address and the
+// event signature of the other filter also match this one
+func (f *LogFilter) covers(other *LogFilter) bool {
+	if len(f.Address) != 0 {
+		if len(other.Address) == 0 {
+			return false
+		}
+		for _, addr := range other.Address {
+			if !containsAddress(f.Address, addr) {
+				return false
+			}
+		}
+	}
+	if len(f.Topics) == 0 || len(f.Topics[0]) == 0 {
+		return true
+	}
+	if len(other.Topics) == 0 || len(other.Topics[0]) == 0 {
+		return false
+	}
+	for _, sig := range other.Topics[0] {
+		if !containsHash(f.Topics[0], sig) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAddress(addrs []web3.Address, addr web3.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func containsHash(hashes []web3.Hash, hash web3.Hash) bool {
+	for _, h := range hashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
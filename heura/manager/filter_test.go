@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/umbracle/go-web3"
+)
+
+func TestLogFilterMatch(t *testing.T) {
+	addr1 := web3.Address{0x1}
+	addr2 := web3.Address{0x2}
+
+	log := &web3.Log{
+		Address: addr1,
+		Topics:  []web3.Hash{transferSig, {0x1}},
+	}
+
+	cases := []struct {
+		filter *LogFilter
+		match  bool
+	}{
+		{&LogFilter{}, true},
+		{&LogFilter{Address: []web3.Address{addr2, addr1}}, true},
+		{&LogFilter{Address: []web3.Address{addr2}}, false},
+		{&LogFilter{Topics: [][]web3.Hash{{approvalSig, transferSig}}}, true},
+		{&LogFilter{Topics: [][]web3.Hash{{approvalSig}}}, false},
+		{&LogFilter{Topics: [][]web3.Hash{nil, {{0x1}}}}, true},
+		{&LogFilter{Topics: [][]web3.Hash{nil, {{0x2}}}}, false},
+		{&LogFilter{Topics: [][]web3.Hash{nil, nil, {{0x1}}}}, false},
+	}
+
+	for indx, c := range cases {
+		if c.filter.Match(log) != c.match {
+			t.Fatalf("case %d: expected match %v", indx, c.match)
+		}
+	}
+}
+
+func TestLogFilterMarshal(t *testing.T) {
+	from, to := uint64(1), uint64(16)
+	addr1 := web3.HexToAddress("0x1111111111111111111111111111111111111111")
+	addr2 := web3.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	cases := []struct {
+		filter   *LogFilter
+		expected string
+	}{
+		{
+			&LogFilter{Address: []web3.Address{addr1}},
+			`{"address":"0x1111111111111111111111111111111111111111","topics":[]}`,
+		},
+		{
+			&LogFilter{Address: []web3.Address{addr1, addr2}, From: &from, To: &to},
+			`{"address":["0x1111111111111111111111111111111111111111","0x2222222222222222222222222222222222222222"],"fromBlock":"0x1","toBlock":"0x10","topics":[]}`,
+		},
+		{
+			&LogFilter{Topics: [][]web3.Hash{{{0x1}, {0x2}}, nil, {{0x3}}}},
+			`{"topics":[["0x0100000000000000000000000000000000000000000000000000000000000000","0x0200000000000000000000000000000000000000000000000000000000000000"],null,"0x0300000000000000000000000000000000000000000000000000000000000000"]}`,
+		},
+	}
+
+	for _, c := range cases {
+		data, err := c.filter.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != c.expected {
+			t.Fatalf("expected %s but found %s", c.expected, string(data))
+		}
+	}
+}
+
+func TestMergeFilters(t *testing.T) {
+	addr1 := web3.Address{0x1}
+	addr2 := web3.Address{0x2}
+
+	transfer := &LogFilter{Address: []web3.Address{addr1}, Topics: [][]web3.Hash{{transferSig}, {{0x1}}}}
+	approval := &LogFilter{Address: []web3.Address{addr2}, Topics: [][]web3.Hash{{approvalSig}}}
+	anyAddress := &LogFilter{Topics: [][]web3.Hash{{transferSig}}}
+
+	merged := mergeFilters([]*LogFilter{transfer, approval})
+	if len(merged.Address) != 2 || len(merged.Topics) != 1 || len(merged.Topics[0]) != 2 {
+		t.Fatal("bad merged filter")
+	}
+	if !merged.covers(transfer) || !merged.covers(approval) || merged.covers(anyAddress) {
+		t.Fatal("bad covers")
+	}
+
+	// the indexed parameters are not merged
+	if !merged.Match(&web3.Log{Address: addr1, Topics: []web3.Hash{transferSig, {0x2}}}) {
+		t.Fatal("it should match any indexed value")
+	}
+
+	merged = mergeFilters([]*LogFilter{transfer, anyAddress})
+	if len(merged.Address) != 0 || len(merged.Topics[0]) != 1 {
+		t.Fatal("it should match any address")
+	}
+	if !merged.covers(transfer) || !merged.covers(anyAddress) || merged.covers(approval) {
+		t.Fatal("bad covers")
+	}
+}
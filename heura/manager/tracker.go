@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"github.com/umbracle/go-web3"
+)
+
+// defaultMaxReorgDepth is the number of recent blocks kept to detect reorgs
+const defaultMaxReorgDepth = 64
+
+// Provider is the ethereum client used by the manager to track the chain
+type Provider interface {
+	BlockNumber() (uint64, error)
+	GetBlockByNumber(i web3.BlockNumber, full bool) (*web3.Block, error)
+	GetLogs(filter *LogFilter) ([]*web3.Log, error)
+}
+
+// blockUpdate is a change on the canonical chain
+type blockUpdate struct {
+	// Removed are the blocks dropped by a reorg, newest first
+	Removed []*web3.Block
+
+	// Added is the new block on top of the canonical chain
+	Added *web3.Block
+}
+
+// blockTracker is a cursor over the chain that returns every block
+// from the last processed one up to the head, in order. It keeps a
+// window with the last processed blocks to detect reorgs.
+type blockTracker struct {
+	provider Provider
+	window   []*web3.Block
+	maxDepth int
+
+	// startDepth is the number of blocks before the head handled on the first sync
+	startDepth uint64
+}
+
+func newBlockTracker(provider Provider) *blockTracker {
+	return &blockTracker{
+		provider: provider,
+		maxDepth: defaultMaxReorgDepth,
+	}
+}
+
+func (b *blockTracker) lastBlock() *web3.Block {
+	if len(b.window) == 0 {
+		return nil
+	}
+	return b.window[len(b.window)-1]
+}
+
+// sync calls handle for every block produced since the last processed one
+// up to the current head, in order. On the first call it starts startDepth
+// blocks behind the head. The cursor moves forward only after handle succeeds, so a failed
+// block is retried on the next call and no block is skipped or handled twice.
+// If the last processed block is not part of the canonical chain anymore,
+// the blocks after the common ancestor are notified as removed.
+func (b *blockTracker) sync(handle func(update *blockUpdate) error) error {
+	head, err := b.provider.BlockNumber()
+	if err != nil {
+		return err
+	}
+
+	for {
+		update := &blockUpdate{}
+		window := b.window
+		reorg := false
+
+		last := b.lastBlock()
+		if last == nil {
+			first := uint64(0)
+			if head > b.startDepth {
+				first = head - b.startDepth
+			}
+			if update.Added, err = b.getBlock(first); err != nil || update.Added == nil {
+				return err
+			}
+		} else if last.Number < head {
+			if update.Added, err = b.getBlock(last.Number + 1); err != nil || update.Added == nil {
+				return err
+			}
+			reorg = update.Added.ParentHash != last.Hash
+		} else {
+			// no new blocks, check that the last one is still in the canonical chain
+			current, err := b.getBlock(last.Number)
+			if err != nil || current == nil || current.Hash == last.Hash {
+				return err
+			}
+			reorg = true
+		}
+
+		if reorg {
+			indx, err := b.findAncestor()
+			if err != nil {
+				return err
+			}
+
+			for i := len(b.window) - 1; i > indx; i-- {
+				update.Removed = append(update.Removed, b.window[i])
+			}
+			window = b.window[: indx+1 : indx+1]
+
+			// resume from the block after the common ancestor
+			next := b.window[0].Number
+			if indx >= 0 {
+				next = b.window[indx].Number + 1
+			}
+			if update.Added, err = b.getBlock(next); err != nil {
+				return err
+			}
+			if update.Added == nil || (indx >= 0 && update.Added.ParentHash != b.window[indx].Hash) {
+				// the chain changed again while handling the reorg, retry later
+				return nil
+			}
+		}
+
+		if err := handle(update); err != nil {
+			return err
+		}
+
+		window = append(window, update.Added)
+		if len(window) > b.maxDepth {
+			window = window[len(window)-b.maxDepth:]
+		}
+		b.window = window
+	}
+}
+
+func (b *blockTracker) getBlock(num uint64) (*web3.Block, error) {
+	return b.provider.GetBlockByNumber(web3.BlockNumber(num), false)
+}
+
+// findAncestor returns the index of the newest block in the window that
+// is still part of the canonical chain or -1 if none of them are
+func (b *blockTracker) findAncestor() (int, error) {
+	for i := len(b.window) - 1; i >= 0; i-- {
+		block, err := b.getBlock(b.window[i].Number)
+		if err != nil {
+			return 0, err
+		}
+		if block != nil && block.Hash == b.window[i].Hash {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,297 @@
+package manager
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/umbracle/go-web3"
+)
+
+// testChain is an in-memory Provider
+type testChain struct {
+	lock   sync.Mutex
+	blocks []*web3.Block
+	logs   map[web3.Hash][]*web3.Log
+	fail   map[uint64]bool
+
+	// queries is the number of range log queries
+	queries int
+
+	// blockQueries is the number of log queries by block hash
+	blockQueries int
+}
+
+func newTestChain() *testChain {
+	c := &testChain{
+		logs: map[web3.Hash][]*web3.Log{},
+		fail: map[uint64]bool{},
+	}
+	c.addBlocks(1)
+	return c
+}
+
+func testHash(num uint64, fork byte) web3.Hash {
+	h := web3.Hash{}
+	h[0] = fork
+	h[31] = byte(num)
+	h[30] = byte(num >> 8)
+	return h
+}
+
+// addBlocks appends n blocks to the canonical chain
+func (c *testChain) addBlocks(n int) {
+	c.addForkBlocks(n, 0)
+}
+
+// reorg replaces the last depth blocks with n blocks of a new fork
+func (c *testChain) reorg(depth int, n int, fork byte) {
+	c.lock.Lock()
+	c.blocks = c.blocks[:len(c.blocks)-depth]
+	c.lock.Unlock()
+
+	c.addForkBlocks(n, fork)
+}
+
+func (c *testChain) addForkBlocks(n int, fork byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for i := 0; i < n; i++ {
+		num := uint64(len(c.blocks))
+		block := &web3.Block{
+			Number:     num,
+			Hash:       testHash(num, fork),
+			Difficulty: big.NewInt(1),
+		}
+		if num > 0 {
+			block.ParentHash = c.blocks[num-1].Hash
+		}
+		c.blocks = append(c.blocks, block)
+	}
+}
+
+func (c *testChain) head() *web3.Block {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.blocks[len(c.blocks)-1]
+}
+
+func (c *testChain) BlockNumber() (uint64, error) {
+	return c.head().Number, nil
+}
+
+func (c *testChain) GetBlockByNumber(i web3.BlockNumber, full bool) (*web3.Block, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if i == web3.Latest {
+		i = web3.BlockNumber(len(c.blocks) - 1)
+	}
+	if c.fail[uint64(i)] {
+		return nil, fmt.Errorf("failed to get block %d", i)
+	}
+	if int(i) >= len(c.blocks) {
+		return nil, nil
+	}
+	return c.blocks[i], nil
+}
+
+func (c *testChain) GetLogs(filter *LogFilter) ([]*web3.Log, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	candidates := []*web3.Log{}
+	if filter.BlockHash != nil {
+		candidates = c.logs[*filter.BlockHash]
+		c.blockQueries++
+	} else {
+		for i := int(*filter.From); i <= int(*filter.To) && i < len(c.blocks); i++ {
+			candidates = append(candidates, c.logs[c.blocks[i].Hash]...)
+		}
+		c.queries++
+	}
+
+	logs := []*web3.Log{}
+	for _, log := range candidates {
+		if filter.Match(log) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func testSync(t *testing.T, tracker *blockTracker) []uint64 {
+	nums := []uint64{}
+	err := tracker.sync(func(update *blockUpdate) error {
+		if len(update.Removed) != 0 {
+			t.Fatal("unexpected reorg")
+		}
+		nums = append(nums, update.Added.Number)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nums
+}
+
+func TestBlockTrackerNoGaps(t *testing.T) {
+	chain := newTestChain()
+	chain.addBlocks(10)
+
+	tracker := newBlockTracker(chain)
+
+	// the first sync only returns the head
+	if nums := testSync(t, tracker); fmt.Sprint(nums) != "[10]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+
+	// nothing new
+	if nums := testSync(t, tracker); len(nums) != 0 {
+		t.Fatalf("bad blocks %v", nums)
+	}
+
+	// several blocks between two syncs
+	chain.addBlocks(3)
+	if nums := testSync(t, tracker); fmt.Sprint(nums) != "[11 12 13]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+}
+
+func TestBlockTrackerRetry(t *testing.T) {
+	chain := newTestChain()
+	tracker := newBlockTracker(chain)
+	testSync(t, tracker)
+
+	chain.addBlocks(3)
+	chain.fail[2] = true
+
+	nums := []uint64{}
+	err := tracker.sync(func(update *blockUpdate) error {
+		nums = append(nums, update.Added.Number)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("it should fail")
+	}
+	if fmt.Sprint(nums) != "[1]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+
+	// the handler fails, the block is not marked as processed
+	chain.fail[2] = false
+	err = tracker.sync(func(update *blockUpdate) error {
+		return fmt.Errorf("failed")
+	})
+	if err == nil {
+		t.Fatal("it should fail")
+	}
+
+	if nums := testSync(t, tracker); fmt.Sprint(nums) != "[2 3]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+}
+
+func TestBlockTrackerReorg(t *testing.T) {
+	chain := newTestChain()
+	chain.addBlocks(5)
+
+	tracker := newBlockTracker(chain)
+	testSync(t, tracker)
+
+	chain.addBlocks(2)
+	testSync(t, tracker)
+
+	// replace blocks 6 and 7 with 3 blocks of a new fork
+	chain.reorg(2, 3, 1)
+
+	updates := []*blockUpdate{}
+	err := tracker.sync(func(update *blockUpdate) error {
+		updates = append(updates, update)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 3 {
+		t.Fatalf("expected 3 updates but found %d", len(updates))
+	}
+
+	removed := updates[0].Removed
+	if len(removed) != 2 || removed[0].Hash != testHash(7, 0) || removed[1].Hash != testHash(6, 0) {
+		t.Fatalf("bad removed blocks %v", removed)
+	}
+	for indx, update := range updates {
+		if indx != 0 && len(update.Removed) != 0 {
+			t.Fatal("only the first update should remove blocks")
+		}
+		if update.Added.Hash != testHash(uint64(6+indx), 1) {
+			t.Fatalf("bad block %d", update.Added.Number)
+		}
+	}
+}
+
+func TestBlockTrackerWindow(t *testing.T) {
+	chain := newTestChain()
+
+	tracker := newBlockTracker(chain)
+	tracker.maxDepth = 3
+	testSync(t, tracker)
+
+	chain.addBlocks(10)
+	testSync(t, tracker)
+
+	if len(tracker.window) != 3 {
+		t.Fatalf("expected window of 3 but found %d", len(tracker.window))
+	}
+
+	// reorg deeper than the window
+	chain.reorg(5, 5, 1)
+
+	updates := []*blockUpdate{}
+	err := tracker.sync(func(update *blockUpdate) error {
+		updates = append(updates, update)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates[0].Removed) != 3 {
+		t.Fatalf("expected all the window to be removed")
+	}
+	if len(updates) != 3 || updates[0].Added.Number != 8 {
+		t.Fatalf("expected to resume from the start of the window")
+	}
+}
+
+func TestBlockTrackerStartDepth(t *testing.T) {
+	chain := newTestChain()
+	chain.addBlocks(1)
+
+	tracker := newBlockTracker(chain)
+	tracker.startDepth = 3
+
+	// not enough blocks, start from genesis
+	if nums := testSync(t, tracker); fmt.Sprint(nums) != "[0 1]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+
+	chain = newTestChain()
+	chain.addBlocks(5)
+
+	tracker = newBlockTracker(chain)
+	tracker.startDepth = 3
+
+	if nums := testSync(t, tracker); fmt.Sprint(nums) != "[2 3 4 5]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+
+	chain.addBlocks(2)
+	if nums := testSync(t, tracker); fmt.Sprint(nums) != "[6 7]" {
+		t.Fatalf("bad blocks %v", nums)
+	}
+}
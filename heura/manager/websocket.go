@@ -0,0 +1,318 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/umbracle/go-web3"
+	"github.com/umbracle/go-web3/jsonrpc/codec"
+)
+
+var (
+	// wsCallTimeout is the time to wait for the response of a request
+	wsCallTimeout = 10 * time.Second
+
+	// wsMaxBackoff is the maximum time to wait between reconnects
+	wsMaxBackoff = 30 * time.Second
+)
+
+// errDisconnected is returned for the requests in flight when the connection drops
+var errDisconnected = fmt.Errorf("websocket disconnected")
+
+func isWebsocket(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://")
+}
+
+// wsClient is a jsonrpc client over websockets that supports subscriptions.
+// If the connection drops it reconnects to the endpoint and starts again all the
+// subscriptions. The blocks produced while disconnected are recovered by the
+// block tracker, which always walks from the last processed block.
+type wsClient struct {
+	url string
+
+	lock    sync.Mutex
+	conn    *websocket.Conn
+	seq     uint64
+	pending map[uint64]chan *codec.Response
+
+	// subs are the active subscriptions, resubscribed after a reconnect
+	subs []*wsSubscription
+
+	// onReconnect is called after a reconnect once the subscriptions are restored
+	onReconnect func()
+
+	closeCh chan struct{}
+}
+
+type wsSubscription struct {
+	id       string
+	params   []interface{}
+	callback func(b []byte)
+}
+
+func newWsClient(url string) (*wsClient, error) {
+	c := &wsClient{
+		url:     url,
+		pending: map[uint64]chan *codec.Response{},
+		closeCh: make(chan struct{}),
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{})
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+
+	go c.run(conn)
+	return c, nil
+}
+
+// run reads the messages from the connection and reconnects when it drops
+func (c *wsClient) run(conn *websocket.Conn) {
+	for {
+		c.read(conn)
+
+		c.lock.Lock()
+		for id, ch := range c.pending {
+			ch <- &codec.Response{Error: &codec.ErrorObject{Message: errDisconnected.Error()}}
+			delete(c.pending, id)
+		}
+		c.conn = nil
+		c.lock.Unlock()
+
+		if conn = c.reconnect(); conn == nil {
+			return
+		}
+		go c.resubscribe()
+	}
+}
+
+func (c *wsClient) read(conn *websocket.Conn) {
+	for {
+		_, buf, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var resp codec.Response
+		if err := json.Unmarshal(buf, &resp); err != nil {
+			continue
+		}
+
+		if resp.ID != 0 {
+			c.lock.Lock()
+			ch, ok := c.pending[resp.ID]
+			delete(c.pending, resp.ID)
+			c.lock.Unlock()
+
+			if ok {
+				ch <- &resp
+			}
+			continue
+		}
+
+		// subscription notification
+		var req codec.Request
+		if err := json.Unmarshal(buf, &req); err != nil || req.Method != "eth_subscription" {
+			continue
+		}
+		var notification codec.Subscription
+		if err := json.Unmarshal(req.Params, &notification); err != nil {
+			continue
+		}
+
+		c.lock.Lock()
+		var callback func(b []byte)
+		for _, sub := range c.subs {
+			if sub.id == notification.ID {
+				callback = sub.callback
+			}
+		}
+		c.lock.Unlock()
+
+		if callback != nil {
+			callback(notification.Result)
+		}
+	}
+}
+
+// reconnect dials the endpoint with an exponential backoff until
+// it succeeds or the client is closed
+func (c *wsClient) reconnect() *websocket.Conn {
+	backoff := 100 * time.Millisecond
+	for {
+		select {
+		case <-c.closeCh:
+			return nil
+		case <-time.After(backoff):
+		}
+
+		conn, _, err := websocket.DefaultDialer.Dial(c.url, http.Header{})
+		if err == nil {
+			c.lock.Lock()
+			c.conn = conn
+			c.lock.Unlock()
+			return conn
+		}
+
+		fmt.Printf("failed to reconnect to %s: %v\n", c.url, err)
+		if backoff *= 2; backoff > wsMaxBackoff {
+			backoff = wsMaxBackoff
+		}
+	}
+}
+
+func (c *wsClient) resubscribe() {
+	c.lock.Lock()
+	subs := append([]*wsSubscription{}, c.subs...)
+	c.lock.Unlock()
+
+	for _, sub := range subs {
+		var id string
+		if err := c.Call("eth_subscribe", &id, sub.params...); err != nil {
+			fmt.Printf("failed to resubscribe: %v\n", err)
+			continue
+		}
+		c.lock.Lock()
+		sub.id = id
+		c.lock.Unlock()
+	}
+
+	if c.onReconnect != nil {
+		c.onReconnect()
+	}
+}
+
+// Call makes a jsonrpc request
+func (c *wsClient) Call(method string, out interface{}, params ...interface{}) error {
+	req := codec.Request{
+		Method: method,
+	}
+	if len(params) > 0 {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = data
+	}
+
+	ch := make(chan *codec.Response, 1)
+
+	c.lock.Lock()
+	if c.conn == nil {
+		c.lock.Unlock()
+		return errDisconnected
+	}
+	c.seq++
+	req.ID = c.seq
+	c.pending[req.ID] = ch
+
+	raw, err := json.Marshal(req)
+	if err == nil {
+		err = c.conn.WriteMessage(websocket.TextMessage, raw)
+	}
+	if err != nil {
+		delete(c.pending, req.ID)
+		c.lock.Unlock()
+		return err
+	}
+	c.lock.Unlock()
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		return json.Unmarshal(resp.Result, out)
+
+	case <-time.After(wsCallTimeout):
+		c.lock.Lock()
+		delete(c.pending, req.ID)
+		c.lock.Unlock()
+		return fmt.Errorf("timeout calling %s", method)
+	}
+}
+
+// Subscribe starts a subscription with eth_subscribe (i.e. "newHeads" or "logs").
+// The subscription is restored after a reconnect.
+func (c *wsClient) Subscribe(callback func(b []byte), params ...interface{}) error {
+	var id string
+	if err := c.Call("eth_subscribe", &id, params...); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.subs = append(c.subs, &wsSubscription{
+		id:       id,
+		params:   params,
+		callback: callback,
+	})
+	c.lock.Unlock()
+	return nil
+}
+
+// SubscribeNewHeads calls the callback with every new head of the chain
+func (c *wsClient) SubscribeNewHeads(callback func(block *web3.Block)) error {
+	return c.Subscribe(func(b []byte) {
+		block := &web3.Block{}
+		if err := block.UnmarshalJSON(b); err == nil {
+			callback(block)
+		}
+	}, "newHeads")
+}
+
+// SubscribeLogs calls the callback with every log that matches the filter
+func (c *wsClient) SubscribeLogs(filter *LogFilter, callback func(log *web3.Log)) error {
+	return c.Subscribe(func(b []byte) {
+		log := &web3.Log{}
+		if err := log.UnmarshalJSON(b); err == nil {
+			callback(log)
+		}
+	}, "logs", filter)
+}
+
+// BlockNumber implements the Provider interface
+func (c *wsClient) BlockNumber() (uint64, error) {
+	var out string
+	if err := c.Call("eth_blockNumber", &out); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimPrefix(out, "0x"), 16, 64)
+}
+
+// GetBlockByNumber implements the Provider interface
+func (c *wsClient) GetBlockByNumber(i web3.BlockNumber, full bool) (*web3.Block, error) {
+	var b *web3.Block
+	if err := c.Call("eth_getBlockByNumber", &b, i.String(), full); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// GetLogs implements the Provider interface
+func (c *wsClient) GetLogs(filter *LogFilter) ([]*web3.Log, error) {
+	var logs []*web3.Log
+	if err := c.Call("eth_getLogs", &logs, filter); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// Close closes the connection and stops reconnecting
+func (c *wsClient) Close() error {
+	close(c.closeCh)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
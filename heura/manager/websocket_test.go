@@ -0,0 +1,248 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/umbracle/go-web3"
+	"github.com/umbracle/go-web3/jsonrpc/codec"
+)
+
+type testWsSub struct {
+	conn   *websocket.Conn
+	params string
+}
+
+// testWsServer is a websocket jsonrpc endpoint backed by a testChain
+type testWsServer struct {
+	chain *testChain
+	srv   *httptest.Server
+
+	lock  sync.Mutex
+	conns []*websocket.Conn
+	subs  map[string]*testWsSub
+	seq   int
+
+	// subscribeCh receives the params of every eth_subscribe request
+	subscribeCh chan string
+}
+
+func newTestWsServer(chain *testChain) *testWsServer {
+	s := &testWsServer{
+		chain:       chain,
+		subs:        map[string]*testWsSub{},
+		subscribeCh: make(chan string, 10),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *testWsServer) url() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+func (s *testWsServer) handle(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	s.conns = append(s.conns, conn)
+	s.lock.Unlock()
+
+	for {
+		_, buf, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req codec.Request
+		if err := json.Unmarshal(buf, &req); err != nil {
+			return
+		}
+		var params []json.RawMessage
+		json.Unmarshal(req.Params, &params)
+
+		var result interface{}
+		switch req.Method {
+		case "eth_blockNumber":
+			num, _ := s.chain.BlockNumber()
+			result = fmt.Sprintf("0x%x", num)
+
+		case "eth_getBlockByNumber":
+			var num string
+			json.Unmarshal(params[0], &num)
+			var i uint64
+			fmt.Sscanf(num, "0x%x", &i)
+			result, _ = s.chain.GetBlockByNumber(web3.BlockNumber(i), false)
+
+		case "eth_getLogs":
+			var filter struct {
+				BlockHash web3.Hash `json:"blockHash"`
+			}
+			json.Unmarshal(params[0], &filter)
+			result, _ = s.chain.GetLogs(&LogFilter{BlockHash: &filter.BlockHash})
+
+		case "eth_subscribe":
+			s.lock.Lock()
+			s.seq++
+			id := fmt.Sprintf("0x%d", s.seq)
+			s.subs[id] = &testWsSub{conn: conn, params: string(req.Params)}
+			s.lock.Unlock()
+
+			s.subscribeCh <- string(req.Params)
+			result = id
+		}
+
+		data, _ := json.Marshal(result)
+		resp, _ := json.Marshal(&codec.Response{ID: req.ID, Result: data})
+
+		s.lock.Lock()
+		conn.WriteMessage(websocket.TextMessage, resp)
+		s.lock.Unlock()
+	}
+}
+
+// notify sends the result to the subscriptions started with the given params
+func (s *testWsServer) notify(params string, result interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, _ := json.Marshal(result)
+	for id, sub := range s.subs {
+		if sub.params != params {
+			continue
+		}
+		notification, _ := json.Marshal(&codec.Subscription{ID: id, Result: data})
+		msg, _ := json.Marshal(&codec.Request{Method: "eth_subscription", Params: notification})
+		sub.conn.WriteMessage(websocket.TextMessage, msg)
+	}
+}
+
+// drop closes all the open connections
+func (s *testWsServer) drop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.subs = map[string]*testWsSub{}
+}
+
+func (s *testWsServer) expectSubscribe(t *testing.T, params string) {
+	select {
+	case found := <-s.subscribeCh:
+		if found != params {
+			t.Fatalf("expected subscription %s but found %s", params, found)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription timeout")
+	}
+}
+
+func TestWebsocketProvider(t *testing.T) {
+	chain := newTestChain()
+	chain.addBlocks(5)
+
+	srv := newTestWsServer(chain)
+	defer srv.srv.Close()
+
+	client, err := newWsClient(srv.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	num, err := client.BlockNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 5 {
+		t.Fatalf("expected 5 but found %d", num)
+	}
+
+	block, err := client.GetBlockByNumber(3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Hash != testHash(3, 0) || block.ParentHash != testHash(2, 0) {
+		t.Fatal("bad block")
+	}
+
+	if block, err = client.GetBlockByNumber(10, false); err != nil || block != nil {
+		t.Fatal("block should not exist")
+	}
+}
+
+func TestWebsocketSubscriptions(t *testing.T) {
+	chain := newTestChain()
+	srv := newTestWsServer(chain)
+	defer srv.srv.Close()
+
+	client, err := newWsClient(srv.url())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	headsCh := make(chan *web3.Block, 10)
+	if err := client.SubscribeNewHeads(func(b *web3.Block) { headsCh <- b }); err != nil {
+		t.Fatal(err)
+	}
+	srv.expectSubscribe(t, `["newHeads"]`)
+
+	addr := web3.HexToAddress("0x1111111111111111111111111111111111111111")
+	logsParams := `["logs",{"address":"0x1111111111111111111111111111111111111111","topics":[]}]`
+
+	logsCh := make(chan *web3.Log, 10)
+	if err := client.SubscribeLogs(&LogFilter{Address: []web3.Address{addr}}, func(l *web3.Log) { logsCh <- l }); err != nil {
+		t.Fatal(err)
+	}
+	srv.expectSubscribe(t, logsParams)
+
+	reconnectCh := make(chan struct{}, 1)
+	client.onReconnect = func() { reconnectCh <- struct{}{} }
+
+	// drop the connection, the client reconnects and subscribes again
+	srv.drop()
+
+	srv.expectSubscribe(t, `["newHeads"]`)
+	srv.expectSubscribe(t, logsParams)
+
+	select {
+	case <-reconnectCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconnect timeout")
+	}
+
+	// the notifications arrive on the new subscriptions
+	chain.addBlocks(1)
+	srv.notify(`["newHeads"]`, chain.head())
+
+	select {
+	case b := <-headsCh:
+		if b.Number != 1 {
+			t.Fatalf("bad head %d", b.Number)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("head timeout")
+	}
+
+	srv.notify(logsParams, &web3.Log{BlockNumber: 1, Address: addr})
+	select {
+	case l := <-logsCh:
+		if l.BlockNumber != 1 || l.Address != addr {
+			t.Fatal("bad log")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("log timeout")
+	}
+}
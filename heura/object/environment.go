@@ -36,6 +36,15 @@ func (e *Environment) AddBuiltin(name string, b *Builtin) {
 	e.Builtins[name] = b
 }
 
+// GetBuiltin returns the builtin registered in the environment or in any of the outer ones
+func (e *Environment) GetBuiltin(name string) (*Builtin, bool) {
+	b, ok := e.Builtins[name]
+	if !ok && e.outer != nil {
+		b, ok = e.outer.GetBuiltin(name)
+	}
+	return b, ok
+}
+
 func (e *Environment) GetContract(name string) *Contract {
 	contract, ok := e.GetContracts()[name]
 	if !ok {
@@ -68,6 +77,16 @@ func (e *Environment) GetOnStatements() []*Event {
 	return events
 }
 
+// GetHook returns the lifecycle hook with the given name, if any
+func (e *Environment) GetHook(name string) *Hook {
+	for _, obj := range e.store {
+		if h, ok := obj.(*Hook); ok && h.Name == name {
+			return h
+		}
+	}
+	return nil
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 
@@ -83,6 +102,20 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign updates the value of the nearest binding of the name in the
+// environment or in any of the outer ones. It returns false if the
+// name is not bound.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
+
 func (e *Environment) GetRPCEndpoint() (string, error) {
 	obj, ok := e.Get("endpoint")
 	if !ok {
@@ -98,6 +131,22 @@ func (e *Environment) GetRPCEndpoint() (string, error) {
 	return address.Value, nil
 }
 
+// GetSigner returns the account bound to 'signer', which sends the
+// transactions when no other account is given
+func (e *Environment) GetSigner() (*Account, error) {
+	obj, ok := e.Get("signer")
+	if !ok {
+		return nil, fmt.Errorf("no account to send the transaction, set 'signer' or use send(account)")
+	}
+
+	account, ok := obj.(*Account)
+	if !ok {
+		return nil, fmt.Errorf("signer is not an account, found %s", obj.Type())
+	}
+
+	return account, nil
+}
+
 func (e *Environment) BuildArgs(envs []string) {
 	elems := []Object{}
 	for _, i := range envs {
@@ -6,11 +6,14 @@ import (
 	"hash/fnv"
 	"math/big"
 	"strings"
+	"sync"
 
 	"github.com/umbracle/go-web3"
 	"github.com/umbracle/go-web3/abi"
 	"github.com/umbracle/heura/helper/hex"
 	"github.com/umbracle/heura/heura/ast"
+	"github.com/umbracle/heura/heura/token"
+	"github.com/umbracle/heura/heura/wallet"
 )
 
 type ObjectType string
@@ -21,14 +24,18 @@ const (
 	ACCOUNT_OBJ      = "ACCOUNT"
 	MULTIPLE_OBJ     = "MULTIPLE"
 	INTEGER_OBJ      = "INTEGER"
+	DECIMAL_OBJ      = "DECIMAL"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	BYTES_OBJ        = "BYTES_OBJ"
 	ADDRESS_OBJ      = "ADDRESS_OBJ"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	EVENT_OBJ        = "EVENT"
+	HOOK_OBJ         = "HOOK"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
@@ -54,6 +61,59 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%s", i.Value.String()) }
 
+// decimalPrecision is the number of decimals shown for a value with no
+// exact decimal representation, i.e. 1 / 3
+const decimalPrecision = 18
+
+// Decimal is a number with a fractional part. The value is exact, it is
+// only rounded when shown.
+type Decimal struct {
+	Value *big.Rat
+}
+
+func (d *Decimal) Type() ObjectType { return DECIMAL_OBJ }
+func (d *Decimal) Inspect() string {
+	str := d.Value.FloatString(decimalPlaces(d.Value))
+	if strings.Contains(str, ".") {
+		str = strings.TrimRight(strings.TrimRight(str, "0"), ".")
+	}
+	if str == "-0" {
+		return "0"
+	}
+	return str
+}
+
+// decimalPlaces returns the number of decimals needed to show the value
+// exactly or decimalPrecision if there is no exact decimal representation
+func decimalPlaces(r *big.Rat) int {
+	denom := new(big.Int).Set(r.Denom())
+
+	twos := 0
+	for denom.Bit(0) == 0 && denom.BitLen() > 1 {
+		denom.Rsh(denom, 1)
+		twos++
+	}
+
+	fives := 0
+	five, q, m := big.NewInt(5), new(big.Int), new(big.Int)
+	for {
+		q.QuoRem(denom, five, m)
+		if m.Sign() != 0 {
+			break
+		}
+		denom.Set(q)
+		fives++
+	}
+
+	if denom.Cmp(big.NewInt(1)) != 0 {
+		return decimalPrecision
+	}
+	if twos > fives {
+		return twos
+	}
+	return fives
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -110,21 +170,73 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break stops the loop that encloses the statement
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+// Continue skips to the next iteration of the loop that encloses the statement
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     token.Position // where the error happened, if known
+	Stack   []Frame        // calls that led to the error, innermost first
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Error() }
+
+// Error returns the message prefixed by the position of the error
+func (e *Error) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Message
+	}
+	return e.Pos.String() + ": " + e.Message
+}
+
+// StackTrace returns the error followed by the calls that led to it
+func (e *Error) StackTrace() string {
+	var out bytes.Buffer
+
+	out.WriteString(e.Error())
+	for _, frame := range e.Stack {
+		out.WriteString("\n\tat " + frame.String())
+	}
+
+	return out.String()
+}
+
+// Frame is a call in the stack trace of an error
+type Frame struct {
+	Name string         // name of the function or the event handler
+	Pos  token.Position // position of the call or the handler
+}
+
+func (f Frame) String() string {
+	if !f.Pos.IsValid() {
+		return f.Name
+	}
+	return fmt.Sprintf("%s (%s)", f.Name, f.Pos)
+}
 
 type Event struct {
-	Contract   string
-	Method     string
-	Address    *web3.Address
-	ABI        *abi.ABI
-	Parameters []*ast.OnIdentifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	// ID identifies the statement, there can be several statements for
+	// the same event or for the overloads of an event
+	ID            string
+	Contract      string
+	Method        string
+	Address       *web3.Address
+	Topics        []*web3.Hash // filters for the indexed parameters, nil matches any value
+	Confirmations uint64
+	ABI           *abi.ABI
+	Parameters    []*ast.OnIdentifier
+	Body          *ast.BlockStatement
+	Env           *Environment
 }
 
 func (e *Event) Type() ObjectType { return EVENT_OBJ }
@@ -132,6 +244,18 @@ func (e *Event) Inspect() string {
 	return "event"
 }
 
+// Hook is a block of the script run on a lifecycle event, i.e. on shutdown
+type Hook struct {
+	Name string
+	Body *ast.BlockStatement
+	Env  *Environment
+}
+
+func (h *Hook) Type() ObjectType { return HOOK_OBJ }
+func (h *Hook) Inspect() string {
+	return "hook " + h.Name
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
@@ -295,6 +419,40 @@ func (i *Instance) Inspect() string {
 
 type Account struct {
 	Addr web3.Address
+	// Key signs the transactions of the account, it is nil if the
+	// account can only be queried
+	Key *wallet.Key
+
+	// the transactions of the account are sent one at a time
+	lock  sync.Mutex
+	nonce uint64
+}
+
+// NewSigner returns the account of the key
+func NewSigner(key *wallet.Key) *Account {
+	return &Account{Addr: key.Address(), Key: key}
+}
+
+// UseNonce calls send with the nonce of the next transaction, which is
+// the highest of the pending nonce of the account in the node and the
+// nonce after the last transaction sent from here. The nonce is only
+// used if send succeeds.
+func (a *Account) UseNonce(pending func() (uint64, error), send func(nonce uint64) error) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	nonce, err := pending()
+	if err != nil {
+		return err
+	}
+	if a.nonce > nonce {
+		nonce = a.nonce
+	}
+	if err := send(nonce); err != nil {
+		return err
+	}
+	a.nonce = nonce + 1
+	return nil
 }
 
 func (a *Account) Type() ObjectType { return ACCOUNT_OBJ }
@@ -2,7 +2,10 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/umbracle/heura/heura/ast"
 	"github.com/umbracle/heura/heura/lexer"
@@ -12,28 +15,42 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SUM         // + or |
+	PRODUCT     // * or &
+	POWER       // **
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.COMMA:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRAKET:  INDEX,
-	token.DOT:      INDEX,
+	token.OR:          LOGICAL_OR,
+	token.AND:         LOGICAL_AND,
+	token.EQ:          EQUALS,
+	token.NOT_EQ:      EQUALS,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.LT_EQ:       LESSGREATER,
+	token.GT_EQ:       LESSGREATER,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.PIPE:        SUM,
+	token.CARET:       SUM,
+	token.COMMA:       SUM,
+	token.SLASH:       PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.PERCENT:     PRODUCT,
+	token.AMPERSAND:   PRODUCT,
+	token.SHIFT_LEFT:  PRODUCT,
+	token.SHIFT_RIGHT: PRODUCT,
+	token.POWER:       POWER,
+	token.LPAREN:      CALL,
+	token.LBRAKET:     INDEX,
+	token.DOT:         INDEX,
 }
 
 type (
@@ -41,21 +58,80 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// syncKeywords are the tokens that start a statement, the parser resumes
+// at them after a syntax error
+var syncKeywords = map[token.TokenType]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.FOR:      true,
+	token.WHILE:    true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.ON:       true,
+	token.IMPORT:   true,
+	token.ARTIFACT: true,
+}
+
+// SyntaxError is an error in the source of a script
+type SyntaxError struct {
+	Pos  token.Position
+	Msg  string
+	Line string // source line of the error
+}
+
+func (e *SyntaxError) Error() string {
+	if !e.Pos.IsValid() {
+		return e.Msg
+	}
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// Highlight returns the error followed by the source line and a marker
+// under the position of the error
+func (e *SyntaxError) Highlight() string {
+	if e.Line == "" {
+		return e.Error()
+	}
+
+	var marker strings.Builder
+	for i := 0; i < e.Pos.Column-1 && i < len(e.Line); i++ {
+		// keep the tabs so the marker is aligned with the source
+		if e.Line[i] == '\t' {
+			marker.WriteByte('\t')
+		} else {
+			marker.WriteByte(' ')
+		}
+	}
+	marker.WriteByte('^')
+
+	return e.Error() + "\n\t" + e.Line + "\n\t" + marker.String()
+}
+
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*SyntaxError
 
 	curToken  token.Token
 	peekToken token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// loopDepth is the number of loops around the current statement
+	loopDepth int
+
+	// braceDepth is the number of open braces up to the current token
+	braceDepth int
+
+	// panicking is set after a syntax error until the parser resumes at the
+	// next statement, the errors found in the meantime are not reported
+	panicking bool
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*SyntaxError{},
 	}
 
 	// Read two tokens, so curToken and peekToken are both set
@@ -65,6 +141,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.DECIMAL, p.parseDecimalLiteral)
 	p.registerPrefix(token.BYTES, p.parseBytesLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -73,6 +150,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.RAW_STRING, p.parseRawStringLiteral)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.LBRAKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionInline)
@@ -86,6 +165,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRAKET, p.parseIndexExpression)
 	p.registerInfix(token.DOT, p.parseDotIndexExpression)
@@ -94,12 +184,30 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) Errors() []string {
+	errors := []string{}
+	for _, err := range p.errors {
+		errors = append(errors, err.Error())
+	}
+	return errors
+}
+
+// SyntaxErrors returns the errors with the source line where they happened
+func (p *Parser) SyntaxErrors() []*SyntaxError {
 	return p.errors
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		if p.braceDepth > 0 {
+			p.braceDepth--
+		}
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -108,6 +216,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for p.curToken.Type != token.EOF {
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(0)
+			continue
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -117,6 +229,28 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// synchronize skips the rest of a statement with a syntax error. It stops
+// after the next ';', before a keyword that starts a statement or at the '}'
+// that closes the block at the given depth.
+func (p *Parser) synchronize(depth int) {
+	p.panicking = false
+
+	for !p.curTokenIs(token.EOF) {
+		if p.braceDepth < depth {
+			return
+		}
+		if p.braceDepth == depth && p.curTokenIs(token.SEMICOLON) {
+			p.nextToken()
+			return
+		}
+
+		p.nextToken()
+		if p.braceDepth == depth && syncKeywords[p.curToken.Type] {
+			return
+		}
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
@@ -131,13 +265,21 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseFunctionLiteral()
 	case token.ON:
 		return p.parseOnStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) parseImportsStatement() *ast.ImportStatement {
-	stmt := &ast.ImportStatement{}
+	stmt := &ast.ImportStatement{Token: p.curToken}
 	stmt.Folders = p.parseImportsExpressions()
 
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -148,7 +290,7 @@ func (p *Parser) parseImportsStatement() *ast.ImportStatement {
 }
 
 func (p *Parser) parseArtifactStatement() *ast.ArtifactStatement {
-	stmt := &ast.ArtifactStatement{}
+	stmt := &ast.ArtifactStatement{Token: p.curToken}
 	stmt.Folders = p.parseImportsExpressions()
 
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -166,7 +308,7 @@ func (p *Parser) parseImportsExpressions() []ast.Expression {
 		values := []ast.Expression{}
 		for _, i := range p.parseExpressionList(token.RPAREN) {
 			if _, ok := i.(*ast.StringLiteral); !ok { // either string (folder) or ident for default values
-				p.errors = append(p.errors, fmt.Sprintf("could not parse artifact with token %s", i.TokenLiteral()))
+				p.errorf(i.Pos(), "could not parse artifact with token %s", i.TokenLiteral())
 				return nil
 			}
 
@@ -272,12 +414,79 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+var assignOperators = map[token.TokenType]bool{
+	token.ASSIGN:          true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+}
+
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{
 		Token: p.curToken,
 	}
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if assignOperators[p.peekToken.Type] {
+		if assign := p.parseAssignStatement(stmt.Expression); assign != nil {
+			return assign
+		}
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// assignTarget turns the dot expressions of an assignment target, which
+// are parsed right-nested, i.e. h.(a.b), into left-nested ones, (h.a).b,
+// so that the last key is the one assigned
+func assignTarget(target ast.Expression) ast.Expression {
+	dot, ok := target.(*ast.IndexExpression)
+	if !ok || dot.Token.Type != token.DOT {
+		return target
+	}
+	right, ok := dot.Index.(*ast.IndexExpression)
+	if !ok {
+		return target
+	}
+
+	// h.(a.b) is (h.a).b and h.(a[b]) is (h.a)[b]
+	left := assignTarget(&ast.IndexExpression{Token: dot.Token, Left: dot.Left, Index: right.Left})
+	return assignTarget(&ast.IndexExpression{Token: right.Token, Left: left, Index: right.Index})
+}
+
+// parseAssignStatement parses an assignment to an identifier,
+// an array index or a hash key, i.e. x = 1 or x[0] += 1
+func (p *Parser) parseAssignStatement(target ast.Expression) *ast.AssignStatement {
+	p.nextToken()
+
+	stmt := &ast.AssignStatement{
+		Token:    p.curToken,
+		Target:   assignTarget(target),
+		Operator: p.curToken.Literal,
+	}
+	target = stmt.Target
+
+	switch obj := target.(type) {
+	case *ast.Identifier:
+	case *ast.IndexExpression:
+		if _, ok := obj.Index.(*ast.Identifier); obj.Token.Type == token.DOT && !ok {
+			p.errorf(target.Pos(), "cannot assign to %s", target.String())
+			return nil
+		}
+	default:
+		p.errorf(target.Pos(), "cannot assign to %s", target.String())
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -324,17 +533,103 @@ func (p *Parser) parseBytesLiteral() ast.Expression {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
-	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+	value, ok := parseNumber(p.curToken.Literal)
+	if !ok || !value.IsInt() {
+		p.errorf(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
-	lit.Value = value
+	if lit.Unit, ok = p.parseUnit(value); !ok {
+		return nil
+	}
+
+	lit.Value = new(big.Int).Set(value.Num())
 	return lit
 }
 
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	tok := p.curToken
+
+	value, ok := parseNumber(tok.Literal)
+	if !ok {
+		p.errorf(tok.Pos, "could not parse %q as decimal", tok.Literal)
+		return nil
+	}
+
+	// with a unit the decimal is an amount of wei, i.e. 1.5 ether
+	unit, ok := p.parseUnit(value)
+	if !ok {
+		return nil
+	}
+	if unit != "" {
+		return &ast.IntegerLiteral{Token: tok, Value: new(big.Int).Set(value.Num()), Unit: unit}
+	}
+
+	return &ast.DecimalLiteral{Token: tok, Value: value}
+}
+
+// units are the suffixes of a number literal with their decimals, i.e. 5 gwei
+var units = map[string]int64{
+	"wei":    0,
+	"kwei":   3,
+	"mwei":   6,
+	"gwei":   9,
+	"szabo":  12,
+	"finney": 15,
+	"ether":  18,
+}
+
+// parseUnit parses the unit that follows a number, if any, and scales the
+// value to wei. It returns false if the amount is not a whole number of wei.
+func (p *Parser) parseUnit(value *big.Rat) (string, bool) {
+	decimals, ok := units[p.peekToken.Literal]
+	if !ok || !p.peekTokenIs(token.IDENT) {
+		return "", true
+	}
+
+	number := p.curToken
+	p.nextToken()
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(decimals), nil)
+	value.Mul(value, new(big.Rat).SetInt(scale))
+	if !value.IsInt() {
+		p.errorf(number.Pos, "%s %s is not a whole number of wei", number.Literal, p.curToken.Literal)
+		return "", false
+	}
+	return p.curToken.Literal, true
+}
+
+// maxExponent is the largest exponent of a number literal, i.e. 1e18
+const maxExponent = 256
+
+// parseNumber parses the literal of a number. The digits can be separated
+// with underscores (1_000) and the number can have an exponent (1e18).
+func parseNumber(literal string) (*big.Rat, bool) {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+		// underscores go between digits
+		if i == 0 || i == len(literal)-1 || !isDigit(literal[i-1]) || !isDigit(literal[i+1]) {
+			return nil, false
+		}
+	}
+	literal = strings.Replace(literal, "_", "", -1)
+
+	if i := strings.IndexAny(literal, "eE"); i != -1 {
+		exp, err := strconv.Atoi(literal[i+1:])
+		if err != nil || exp > maxExponent {
+			return nil, false
+		}
+	}
+
+	return new(big.Rat).SetString(literal)
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -356,6 +651,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// exponentiation is right associative, 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
@@ -421,11 +720,25 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		Token: p.curToken,
 	}
 	block.Statements = []ast.Statement{}
+	depth := p.braceDepth
 
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.errorf(block.Token.Pos, "block is not closed, expected }")
+			break
+		}
+
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize(depth)
+			if p.braceDepth < depth {
+				// the statement consumed the end of the block
+				break
+			}
+			continue
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
@@ -435,8 +748,129 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	return block
 }
 
+// parseFunctionBody parses the body of a function or an event handler,
+// the loops around it do not apply to the break and continue statements
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	depth := p.loopDepth
+	p.loopDepth = 0
+	defer func() {
+		p.loopDepth = depth
+	}()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() {
+		p.loopDepth--
+	}()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	// for k, v in ...
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	// for i in 0..10
+	if p.peekTokenIs(token.DOTDOT) {
+		if stmt.Key != nil {
+			p.errorf(stmt.Key.Pos(), "range loops take a single variable")
+			return nil
+		}
+
+		p.nextToken()
+		p.nextToken()
+		stmt.End = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorf(p.curToken.Pos, "break outside of a loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.errorf(p.curToken.Pos, "continue outside of a loop")
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseOnStatement() *ast.OnStatement {
-	lit := &ast.OnStatement{}
+	lit := &ast.OnStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -447,6 +881,13 @@ func (p *Parser) parseOnStatement() *ast.OnStatement {
 		Value: p.curToken.Literal,
 	}
 
+	// lifecycle hook, i.e. on shutdown {}
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		lit.Body = p.parseFunctionBody()
+		return lit
+	}
+
 	if p.peekTokenIs(token.LPAREN) {
 		p.nextToken()
 		p.nextToken()
@@ -461,15 +902,30 @@ func (p *Parser) parseOnStatement() *ast.OnStatement {
 		lit.Address = expr
 	}
 
-	if !p.expectPeek(token.DOT) {
-		return nil
-	}
-
-	p.nextToken()
+	// the event is either a name or a signature between brackets for
+	// overloaded events, i.e. on ERC20["Transfer(address,address,uint256)"]
+	if p.peekTokenIs(token.LBRAKET) {
+		p.nextToken()
+		if !p.expectPeek(token.STRING) {
+			return nil
+		}
+		lit.Method = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
+		if !p.expectPeek(token.RBRAKET) {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.DOT) {
+			return nil
+		}
+		p.nextToken()
 
-	lit.Method = &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
+		lit.Method = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
 	}
 
 	if !p.expectPeek(token.LPAREN) {
@@ -478,11 +934,26 @@ func (p *Parser) parseOnStatement() *ast.OnStatement {
 
 	lit.Parameters = p.parseEventParameters()
 
+	// optional modifiers, i.e. confirmations 12
+	for p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+
+		switch p.curToken.Literal {
+		case "confirmations":
+			p.nextToken()
+			lit.Confirmations = p.parseExpression(LOWEST)
+
+		default:
+			p.errorf(p.curToken.Pos, "unknown on statement modifier %s", p.curToken.Literal)
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody()
 	return lit
 }
 
@@ -501,7 +972,7 @@ func (p *Parser) parseFunctionInline() ast.Expression {
 		return nil
 	}
 
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody()
 	return lit
 }
 
@@ -532,7 +1003,7 @@ func (p *Parser) parseFunctionLiteral() *ast.FunctionLiteral {
 		return nil
 	}
 
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody()
 
 	return lit
 }
@@ -661,13 +1132,166 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseStringLiteral parses a string with escape sequences and interpolated
+// expressions, i.e. "transfer of ${value}\n"
 func (p *Parser) parseStringLiteral() ast.Expression {
+	tok := p.curToken
+	raw := tok.Literal
+
+	parts := []ast.Expression{}
+	var text strings.Builder
+
+	addText := func() {
+		if text.Len() != 0 {
+			parts = append(parts, &ast.StringLiteral{Token: tok, Value: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == '\\':
+			ch, size, ok := unescape(raw[i:])
+			if !ok {
+				p.errorf(stringPos(tok, i), "invalid escape sequence %s", raw[i:i+size])
+				return nil
+			}
+			text.WriteString(ch)
+			i += size - 1
+
+		case raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			end := interpolationEnd(raw, i+2)
+			expr := p.parseInterpolation(stringPos(tok, i+2), raw[i+2:end])
+			if expr == nil {
+				return nil
+			}
+			addText()
+			parts = append(parts, expr)
+			i = end
+
+		default:
+			text.WriteByte(raw[i])
+		}
+	}
+
+	if len(parts) == 0 {
+		return &ast.StringLiteral{Token: tok, Value: text.String()}
+	}
+	addText()
+
+	return &ast.InterpolatedString{Token: tok, Parts: parts}
+}
+
+// parseInterpolation parses an expression interpolated in a string
+func (p *Parser) parseInterpolation(pos token.Position, input string) ast.Expression {
+	if strings.TrimSpace(input) == "" {
+		p.errorf(pos, "empty expression in string")
+		return nil
+	}
+
+	sub := New(lexer.NewAt(pos, input))
+	expr := sub.parseExpression(LOWEST)
+	if len(sub.errors) == 0 && !sub.peekTokenIs(token.EOF) {
+		sub.peekError(token.EOF)
+	}
+
+	if len(sub.errors) != 0 {
+		p.errorf(sub.errors[0].Pos, "%s", sub.errors[0].Msg)
+		return nil
+	}
+	return expr
+}
+
+func (p *Parser) parseRawStringLiteral() ast.Expression {
 	return &ast.StringLiteral{
 		Token: p.curToken,
 		Value: p.curToken.Literal,
 	}
 }
 
+func (p *Parser) parseIllegal() ast.Expression {
+	literal := p.curToken.Literal
+	if strings.HasPrefix(literal, "\"") || strings.HasPrefix(literal, "`") {
+		p.errorf(p.curToken.Pos, "string literal not terminated")
+	} else {
+		p.errorf(p.curToken.Pos, "unexpected character %s", literal)
+	}
+	return nil
+}
+
+// stringPos returns the position of the byte at the offset of the contents
+// of a string token
+func stringPos(tok token.Token, offset int) token.Position {
+	pos := tok.Pos
+	pos.Column++ // the opening quote
+
+	for _, ch := range []byte(tok.Literal[:offset]) {
+		if ch == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+// unescape decodes the escape sequence at the start of the string. It
+// returns the decoded text and the length of the sequence.
+func unescape(str string) (string, int, bool) {
+	if len(str) < 2 {
+		return "", len(str), false
+	}
+
+	switch str[1] {
+	case 'n':
+		return "\n", 2, true
+	case 't':
+		return "\t", 2, true
+	case 'r':
+		return "\r", 2, true
+	case '\\', '"', '$':
+		return string(str[1]), 2, true
+	case 'u':
+		// \u followed by 4 hex digits, i.e. \u00e9
+		if len(str) < 6 {
+			return "", len(str), false
+		}
+		code, err := strconv.ParseUint(str[2:6], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", 6, false
+		}
+		return string(rune(code)), 6, true
+	}
+	return "", 2, false
+}
+
+// interpolationEnd returns the index of the brace that closes the expression
+// interpolated at the start index of the string
+func interpolationEnd(str string, start int) int {
+	depth := 1
+	for i := start; i < len(str); i++ {
+		switch str[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"', '`':
+			// skip the strings in the expression
+			quote := str[i]
+			for i++; i < len(str) && str[i] != quote; i++ {
+				if str[i] == '\\' && quote == '"' {
+					i++
+				}
+			}
+		}
+	}
+	return len(str)
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -730,20 +1354,52 @@ func (p *Parser) parseDotIndexExpression(left ast.Expression) ast.Expression {
 		Left:  left,
 	}
 
+	// the right side includes calls and other dot and index expressions, i.e.
+	// token.transfer(to, value).send(), but not the binary operators, so
+	// a.value > b.value compares two fields
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	exp.Index = p.parseExpression(PREFIX)
 
 	return exp
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{
-		Token: p.curToken,
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRAKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{
+		Token: tok,
 		Left:  left,
+		Index: index,
+	}
+}
+
+// parseSliceExpression parses the rest of left[start:end] from the colon
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{
+		Token: tok,
+		Left:  left,
+		Start: start,
 	}
 
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.RBRAKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRAKET) {
 		return nil
@@ -816,8 +1472,29 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.peekToken.Pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+// errorf records a syntax error at the given position of the source. The
+// errors that follow it until the parser resumes at the next statement
+// are not reported.
+func (p *Parser) errorf(pos token.Position, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+
+	err := &SyntaxError{
+		Pos:  pos,
+		Msg:  fmt.Sprintf(format, a...),
+		Line: p.l.Line(pos.Line),
+	}
+	for _, e := range p.errors {
+		if e.Error() == err.Error() {
+			return
+		}
+	}
+	p.errors = append(p.errors, err)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -829,6 +1506,5 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.curToken.Pos, "unexpected %s, expected an expression", t)
 }
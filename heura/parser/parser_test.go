@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -15,6 +16,11 @@ func TestOnStatements(t *testing.T) {
 	}{
 		{"on ERC20.Transfer(x=1) {}"},
 		{"on ERC20(\"\").Transfer() {}"},
+		{"on ERC20.Transfer(x) confirmations 12 {}"},
+		{"on ERC20.Transfer(x) confirmations N {}"},
+		{"on shutdown { let x = 1; }"},
+		{`on ERC20["Transfer(address,address,uint256)"](from, to, value) {}`},
+		{`on ERC20(0x1)["Transfer(address,address,uint256)"](from, to, value) {}`},
 	}
 
 	for _, tt := range tests {
@@ -26,6 +32,41 @@ func TestOnStatements(t *testing.T) {
 	}
 }
 
+func TestOnStatementConfirmations(t *testing.T) {
+	p := New(lexer.New("on ERC20.Transfer(x) confirmations 12 {}"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.OnStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.OnStatement. got=%T", program.Statements[0])
+	}
+	testIntegerLiteral(t, stmt.Confirmations, 12)
+
+	p = New(lexer.New("on ERC20.Transfer(x) other 12 {}"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatal("expected an error for an unknown modifier")
+	}
+}
+
+func TestOnHook(t *testing.T) {
+	p := New(lexer.New("on shutdown { 1 }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.OnStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.OnStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Contract.Value != "shutdown" || stmt.Method != nil {
+		t.Fatalf("bad hook %s", stmt.Contract.Value)
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("expected one statement in the body but found %d", len(stmt.Body.Statements))
+	}
+}
+
 func TestArtifactStatement(t *testing.T) {
 	tests := []struct {
 		input   string
@@ -168,6 +209,99 @@ func TestIdentifierExpression(t *testing.T) {
 	}
 }
 
+func TestDecimalLiteralExpression(t *testing.T) {
+	p := New(lexer.New("1.25;"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.DecimalLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value.Cmp(big.NewRat(5, 4)) != 0 {
+		t.Errorf("literal.Value not 5/4. got=%s", literal.Value)
+	}
+	if literal.TokenLiteral() != "1.25" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "1.25", literal.TokenLiteral())
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // value of the literal
+		unit     string
+	}{
+		{"1000000000000000000000", "1000000000000000000000", ""},
+		{"1_000_000", "1000000", ""},
+		{"1e18", "1000000000000000000", ""},
+		{"2E3", "2000", ""},
+		{"5 gwei", "5000000000", "gwei"},
+		{"1.5 ether", "1500000000000000000", "ether"},
+		{"1_000 wei", "1000", "wei"},
+		{"1.5", "3/2", ""},
+		{"1.5e3", "1500/1", ""},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s: program has not 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+		switch literal := stmt.Expression.(type) {
+		case *ast.IntegerLiteral:
+			if literal.Value.String() != tt.expected {
+				t.Errorf("%s: value not %s. got=%s", tt.input, tt.expected, literal.Value)
+			}
+			if literal.Unit != tt.unit {
+				t.Errorf("%s: unit not %q. got=%q", tt.input, tt.unit, literal.Unit)
+			}
+			if literal.String() != tt.input {
+				t.Errorf("%s: String() not %q. got=%q", tt.input, tt.input, literal.String())
+			}
+		case *ast.DecimalLiteral:
+			if literal.Value.String() != tt.expected {
+				t.Errorf("%s: value not %s. got=%s", tt.input, tt.expected, literal.Value)
+			}
+		default:
+			t.Errorf("%s: not a number literal. got=%T", tt.input, stmt.Expression)
+		}
+	}
+}
+
+func TestNumberLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1__000", `1:1: could not parse "1__000" as integer`},
+		{"1000_", `1:1: could not parse "1000_" as integer`},
+		{"1e1000", `1:1: could not parse "1e1000" as integer`},
+		{"1.5 wei", "1:1: 1.5 wei is not a whole number of wei"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%s: expected error %q. got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestIntegerLiteralExpression(t *testing.T) {
 	input := "5;"
 
@@ -190,8 +324,8 @@ func TestIntegerLiteralExpression(t *testing.T) {
 		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
 	}
 
-	if literal.Value != 5 {
-		t.Errorf("literal.Value not %d. got=%d", 5, literal.Value)
+	if literal.Value.Cmp(big.NewInt(5)) != 0 {
+		t.Errorf("literal.Value not %d. got=%s", 5, literal.Value)
 	}
 
 	if literal.TokenLiteral() != "5" {
@@ -256,6 +390,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 >= 5", 5, ">=", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 ** 5", 5, "**", 5},
+		{"5 & 5", 5, "&", 5},
+		{"5 | 5", 5, "|", 5},
+		{"5 ^ 5", 5, "^", 5},
+		{"5 << 5", 5, "<<", 5},
+		{"5 >> 5", 5, ">>", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -376,6 +521,34 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-(5 + 5)",
 			"(-(5 + 5))",
 		},
+		{
+			"a > 1 && b == c || d",
+			"(((a > 1) && (b == c)) || d)",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"2 * 3 ** 2 ** 2",
+			"(2 * (3 ** (2 ** 2)))",
+		},
+		{
+			"a % b + c",
+			"((a % b) + c)",
+		},
+		{
+			"a & 1 == 1",
+			"((a & 1) == 1)",
+		},
+		{
+			"a | b << 2 ^ c",
+			"((a | (b << 2)) ^ c)",
+		},
 		{
 			"!(true == true)",
 			"(!(true == true))",
@@ -400,6 +573,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a.value > b.value",
+			"((a[value]) > (b[value]))",
+		},
+		{
+			"a.b.c * 2 ** -x.y",
+			"((a[(b[c])]) * (2 ** (-(x[y]))))",
+		},
+		{
+			"t.transfer(a, b + 1).send() == c",
+			"((t[(transfer(a, (b + 1))[send()])]) == c)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -491,6 +676,164 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		key      string
+		value    string
+		iterable string
+		end      string
+	}{
+		{"for x in xs { x }", "", "x", "xs", ""},
+		{"for k, v in {1: 2} { k }", "k", "v", "{1:2}", ""},
+		{"for i in 0..n + 1 { break; }", "", "i", "0", "(n + 1)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Body does not contain %d statements. got=%d\n", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T", program.Statements[0])
+		}
+
+		key := ""
+		if stmt.Key != nil {
+			key = stmt.Key.Value
+		}
+		end := ""
+		if stmt.End != nil {
+			end = stmt.End.String()
+		}
+		if key != tt.key || stmt.Value.Value != tt.value || stmt.Iterable.String() != tt.iterable || end != tt.end {
+			t.Fatalf("bad for statement %s", stmt.String())
+		}
+		if len(stmt.Body.Statements) != 1 {
+			t.Fatalf("body is not 1 statements. got=%d", len(stmt.Body.Statements))
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	p := New(lexer.New("while x < 10 { if (x == 5) { continue; } }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestLoopSemicolon(t *testing.T) {
+	tests := []string{
+		"for i in 0..3 { i }; x",
+		"for x in xs { x }; x",
+		"while c { c }; x",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("%s: expected 2 statements but found %d", input, len(program.Statements))
+		}
+		if !testIdentifier(t, program.Statements[1].(*ast.ExpressionStatement).Expression, "x") {
+			return
+		}
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []string{
+		"break;",
+		"continue;",
+		"for x in xs { fn a() { break; } }",
+		"for k, v in 0..10 {}",
+		"for x xs {}",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected an error for %s", input)
+		}
+	}
+}
+
+func TestAssignStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		expected string
+	}{
+		{"x = 5;", "=", "x = 5;"},
+		{"x += y * 2", "+=", "x += (y * 2);"},
+		{"x -= 1;", "-=", "x -= 1;"},
+		{"x *= 2;", "*=", "x *= 2;"},
+		{"x /= 2;", "/=", "x /= 2;"},
+		{"a[0] = 1;", "=", "(a[0]) = 1;"},
+		{`h["a"] += 1;`, "+=", "(h[a]) += 1;"},
+		{"h.a = 1;", "=", "(h[a]) = 1;"},
+		{"h.a.b.c = 1;", "=", "(((h[a])[b])[c]) = 1;"},
+		{`h.a["b"] -= 1;`, "-=", "((h[a])[b]) -= 1;"},
+		{`h["a"].b = 1;`, "=", "((h[a])[b]) = 1;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.AssignStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Operator != tt.operator {
+			t.Errorf("stmt.Operator is not %s. got=%s", tt.operator, stmt.Operator)
+		}
+		if stmt.String() != tt.expected {
+			t.Errorf("expected %q. got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []string{
+		"5 = x;",
+		"f() = 1;",
+		"x + 1 = 2;",
+		"h.f() = 1;",
+		"h.a.f() = 1;",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected an error for %s", input)
+		}
+	}
+}
+
 func TestIfElseExpression(t *testing.T) {
 	input := `if (x < y) { x } else { y }`
 
@@ -679,6 +1022,90 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a\nb"`, "a\nb"},
+		{`"a\tb\r"`, "a\tb\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"caf\u00e9"`, "café"},
+		{`"\${value}"`, "${value}"},
+		{`"$ and {}"`, "$ and {}"},
+		{"`raw\n\\n ${x}`", "raw\n\\n ${x}"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestInterpolatedString(t *testing.T) {
+	p := New(lexer.New(`"transfer of ${value * 2} from ${h["from"]}"`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	expected := []string{"transfer of ", "(value * 2)", " from ", "(h[from])"}
+	if len(str.Parts) != len(expected) {
+		t.Fatalf("wrong number of parts. expected=%d, got=%d", len(expected), len(str.Parts))
+	}
+	for i, part := range str.Parts {
+		value := part.String()
+		if lit, ok := part.(*ast.StringLiteral); ok {
+			value = lit.Value
+		}
+		if value != expected[i] {
+			t.Errorf("part %d not %q. got=%q", i, expected[i], value)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = "not closed`, "1:9: string literal not terminated"},
+		{"let a = `not closed", "1:9: string literal not terminated"},
+		{`"a\qb"`, `1:3: invalid escape sequence \q`},
+		{`"a\u00"`, `1:3: invalid escape sequence \u00`},
+		{`"a ${}"`, "1:6: empty expression in string"},
+		{`"a ${x +}"`, "1:9: unexpected EOF, expected an expression"},
+		{`"a ${x y}"`, "1:8: expected next token to be EOF, got IDENT instead"},
+		{"\"line\n${x +}\"", "2:6: unexpected EOF, expected an expression"},
+		{"let a = 1 @ 2;", "1:11: unexpected character @"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: expected error %q. got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -726,6 +1153,34 @@ func TestParsingIndexExpression(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"s[1:4]", "(s[1:4])"},
+		{"s[:n - 1]", "(s[:(n - 1)])"},
+		{"s[1:]", "(s[1:])"},
+		{"s[:]", "(s[:])"},
+		{"a[0][1:2]", "((a[0])[1:2])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKey(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 
@@ -869,8 +1324,8 @@ func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
 		return false
 	}
 
-	if integ.Value != value {
-		t.Errorf("integ.Value not %d. got=%d", value, integ.Value)
+	if !integ.Value.IsInt64() || integ.Value.Int64() != value {
+		t.Errorf("integ.Value not %d. got=%s", value, integ.Value)
 		return false
 	}
 
@@ -961,3 +1416,115 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 
 	return true
 }
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;\nlet = 10;", "test.hra:2:5: expected next token to be IDENT, got = instead"},
+		{"let x = (1 + 2;", "test.hra:1:15: expected next token to be ), got ; instead"},
+		{"fn a() {\n  break;\n}", "test.hra:2:3: break outside of a loop"},
+		{"x = 1;\n5 = x;", "test.hra:2:1: cannot assign to 5"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewFile("test.hra", tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected an error for %q", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements int
+	}{
+		{
+			"let = 1;\nlet y = 2;\nlet z = (3 + ;\nlet w = 4;",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"3:14: unexpected ;, expected an expression",
+			},
+			2,
+		},
+		{
+			"fn a() {\n\tlet = 1;\n\tlet b = 2;\n}\nlet c = ;",
+			[]string{
+				"2:6: expected next token to be IDENT, got = instead",
+				"5:9: unexpected ;, expected an expression",
+			},
+			1,
+		},
+		{
+			"let h = {\"a\": };\nlet y = 1;\n}\nlet z = 1 +;",
+			[]string{
+				"1:15: unexpected }, expected an expression",
+				"3:1: unexpected }, expected an expression",
+				"4:12: unexpected ;, expected an expression",
+			},
+			1,
+		},
+		{
+			"on A.B(a b) {\n\tlet = 1;\n}\nlet x = 1;\nfor x xs { y }",
+			[]string{
+				"1:10: expected next token to be ), got IDENT instead",
+				"5:7: expected next token to be IN, got IDENT instead",
+			},
+			1,
+		},
+		{
+			"fn a() {\n\tlet b = 2;\n",
+			[]string{
+				"1:8: block is not closed, expected }",
+			},
+			0,
+		},
+		{
+			"let x = 1 /* not closed",
+			[]string{},
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.errors) {
+			t.Fatalf("expected %d errors for %q. got=%v", len(tt.errors), tt.input, errors)
+		}
+		for i, err := range tt.errors {
+			if errors[i] != err {
+				t.Errorf("wrong error %d. expected=%q, got=%q", i, err, errors[i])
+			}
+		}
+		if len(program.Statements) != tt.statements {
+			t.Errorf("expected %d statements for %q. got=%d", tt.statements, tt.input, len(program.Statements))
+		}
+	}
+}
+
+func TestSyntaxErrorHighlight(t *testing.T) {
+	p := New(lexer.NewFile("test.hra", "let x = 1;\nif (x) {\n\tlet = 2;\n}"))
+	p.ParseProgram()
+
+	errors := p.SyntaxErrors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error. got=%d", len(errors))
+	}
+
+	expected := "test.hra:3:6: expected next token to be IDENT, got = instead\n\t\tlet = 2;\n\t\t    ^"
+	if errors[0].Highlight() != expected {
+		t.Errorf("wrong highlight.\nexpected=%q\ngot=%q", expected, errors[0].Highlight())
+	}
+}
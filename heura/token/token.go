@@ -1,14 +1,18 @@
 package token
 
+import "fmt"
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
 	// Identifiers + literals
-	IDENT  = "IDENT" // add, foobar, x, y, ...
-	INT    = "INT"   // 1343456
-	STRING = "STRING"
-	BYTES  = "BYTES"
+	IDENT      = "IDENT"   // add, foobar, x, y, ...
+	INT        = "INT"     // 1343456
+	DECIMAL    = "DECIMAL" // 1.5
+	STRING     = "STRING"
+	RAW_STRING = "RAW_STRING" // `multi-line string`
+	BYTES      = "BYTES"
 
 	// Operators
 	ASSIGN   = "="
@@ -17,9 +21,27 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	AND = "&&"
+	OR  = "||"
+
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
 
-	LT = "<"
-	GT = ">"
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
 	EQ     = "=="
 	NOT_EQ = "!="
@@ -35,6 +57,7 @@ const (
 	RBRAKET   = "]"
 	COLON     = ":"
 	DOT       = "."
+	DOTDOT    = ".."
 
 	// Keywords
 	ARTIFACT = "ARTIFACT"
@@ -47,6 +70,11 @@ const (
 	ON       = "ON"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	FOR      = "FOR"
+	IN       = "IN"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 type TokenType string
@@ -54,6 +82,31 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position is the location of a token in the source of a script
+type Position struct {
+	File   string
+	Line   int // starts at 1
+	Column int // starts at 1, in bytes
+}
+
+// IsValid returns true if the position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as file:line:column, i.e. script.hra:12:5
+func (p Position) String() string {
+	if !p.IsValid() {
+		return p.File
+	}
+	pos := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.File != "" {
+		pos = p.File + ":" + pos
+	}
+	return pos
 }
 
 var keywords = map[string]TokenType{
@@ -67,6 +120,11 @@ var keywords = map[string]TokenType{
 	"on":       ON,
 	"else":     ELSE,
 	"return":   RETURN,
+	"for":      FOR,
+	"in":       IN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	web3 "github.com/umbracle/go-web3"
+	"golang.org/x/crypto/sha3"
+
+	"github.com/umbracle/heura/helper/hex"
+)
+
+// Key is a secp256k1 private key that signs transactions
+type Key struct {
+	priv *secp256k1.PrivateKey
+	addr web3.Address
+}
+
+// NewKey returns the key for the 32 bytes of a private key
+func NewKey(b []byte) (*Key, error) {
+	if len(b) != 32 {
+		return nil, fmt.Errorf("private key must be 32 bytes, found %d", len(b))
+	}
+
+	var scalar secp256k1.ModNScalar
+	if overflow := scalar.SetByteSlice(b); overflow || scalar.IsZero() {
+		return nil, fmt.Errorf("invalid private key")
+	}
+	priv := secp256k1.NewPrivateKey(&scalar)
+
+	// the address is the hash of the uncompressed public key without
+	// its 0x04 prefix
+	pub := priv.PubKey().SerializeUncompressed()
+	hash := keccak256(pub[1:])
+
+	key := &Key{priv: priv}
+	copy(key.addr[:], hash[12:])
+	return key, nil
+}
+
+// NewKeyFromHex returns the key for a hex encoded private key, with or
+// without the 0x prefix
+func NewKeyFromHex(str string) (*Key, error) {
+	b, err := hex.DecodeHex(strings.TrimSpace(str))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode private key: %v", err)
+	}
+	return NewKey(b)
+}
+
+// Address returns the address of the key
+func (k *Key) Address() web3.Address {
+	return k.addr
+}
+
+// Sign signs the hash with the deterministic nonces of RFC 6979 and returns
+// the r and s values, with a low s, and the recovery id
+func (k *Key) Sign(hash []byte) (r, s *big.Int, recid byte) {
+	// the compact signature is <27 + recid><r><s>
+	sig := ecdsa.SignCompact(k.priv, hash, false)
+	return new(big.Int).SetBytes(sig[1:33]), new(big.Int).SetBytes(sig[33:]), sig[0] - 27
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
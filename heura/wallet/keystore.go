@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/umbracle/heura/helper/hex"
+)
+
+// keystore is a version 3 key file as stored by go-ethereum. The keys are
+// matched case insensitively, older files use "Crypto" instead of "crypto"
+type keystore struct {
+	Address string
+	Version int
+	Crypto  keystoreCrypto
+}
+
+type keystoreCrypto struct {
+	Cipher       string
+	CipherText   string
+	CipherParams struct {
+		IV string
+	}
+	KDF       string
+	KDFParams struct {
+		// scrypt
+		N int
+		R int
+		P int
+		// pbkdf2
+		C   int
+		PRF string
+		// both
+		DKLen int
+		Salt  string
+	}
+	MAC string
+}
+
+// ReadKeystore reads and decrypts a keystore file with the password
+func ReadKeystore(path, password string) (*Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptKeystore(data, password)
+}
+
+// DecryptKeystore decrypts the json of a keystore file with the password
+func DecryptKeystore(data []byte, password string) (*Key, error) {
+	var ks keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, fmt.Errorf("failed to decode keystore: %v", err)
+	}
+	if ks.Version != 3 {
+		return nil, fmt.Errorf("keystore version %d not supported", ks.Version)
+	}
+	c := ks.Crypto
+	if c.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("keystore cipher %q not supported", c.Cipher)
+	}
+
+	salt, err := hex.DecodeHex(c.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keystore salt: %v", err)
+	}
+	params := c.KDFParams
+
+	var derived []byte
+	switch c.KDF {
+	case "scrypt":
+		derived, err = scrypt.Key([]byte(password), salt, params.N, params.R, params.P, params.DKLen)
+		if err != nil {
+			return nil, err
+		}
+	case "pbkdf2":
+		if params.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("keystore prf %q not supported", params.PRF)
+		}
+		derived = pbkdf2.Key([]byte(password), salt, params.C, params.DKLen, sha256.New)
+	default:
+		return nil, fmt.Errorf("keystore kdf %q not supported", c.KDF)
+	}
+	if len(derived) < 32 {
+		return nil, fmt.Errorf("keystore derived key must be at least 32 bytes, found %d", len(derived))
+	}
+
+	cipherText, err := hex.DecodeHex(c.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keystore ciphertext: %v", err)
+	}
+	mac, err := hex.DecodeHex(c.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keystore mac: %v", err)
+	}
+	if !bytes.Equal(keccak256(derived[16:32], cipherText), mac) {
+		return nil, fmt.Errorf("could not decrypt keystore with the given password")
+	}
+
+	iv, err := hex.DecodeHex(c.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode keystore iv: %v", err)
+	}
+	block, err := aes.NewCipher(derived[:16])
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("keystore iv must be %d bytes, found %d", block.BlockSize(), len(iv))
+	}
+	priv := make([]byte, len(cipherText))
+	cipher.NewCTR(block, iv).XORKeyStream(priv, cipherText)
+
+	key, err := NewKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	if ks.Address != "" && !strings.EqualFold(strings.TrimPrefix(ks.Address, "0x"), hex.EncodeToString(key.addr[:])) {
+		return nil, fmt.Errorf("keystore key does not match the address %s", ks.Address)
+	}
+	return key, nil
+}
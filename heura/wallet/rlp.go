@@ -0,0 +1,49 @@
+package wallet
+
+import (
+	"math/big"
+)
+
+// rlpList is a list of RLP items. The items are []byte, uint64, *big.Int
+// (nil is zero) or nested lists
+type rlpList []interface{}
+
+// encodeRLP returns the RLP encoding of an item
+func encodeRLP(item interface{}) []byte {
+	switch item := item.(type) {
+	case []byte:
+		if len(item) == 1 && item[0] < 0x80 {
+			return []byte{item[0]}
+		}
+		return append(rlpHeader(0x80, len(item)), item...)
+
+	case uint64:
+		return encodeRLP(new(big.Int).SetUint64(item).Bytes())
+
+	case *big.Int:
+		if item == nil {
+			return encodeRLP([]byte{})
+		}
+		return encodeRLP(item.Bytes())
+
+	case rlpList:
+		var payload []byte
+		for _, elem := range item {
+			payload = append(payload, encodeRLP(elem)...)
+		}
+		return append(rlpHeader(0xc0, len(payload)), payload...)
+
+	default:
+		panic("rlp: unsupported type")
+	}
+}
+
+// rlpHeader returns the prefix of a string (0x80) or a list (0xc0) with
+// the given length
+func rlpHeader(offset byte, length int) []byte {
+	if length < 56 {
+		return []byte{offset + byte(length)}
+	}
+	size := new(big.Int).SetUint64(uint64(length)).Bytes()
+	return append([]byte{offset + 55 + byte(len(size))}, size...)
+}
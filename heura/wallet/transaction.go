@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"math/big"
+
+	web3 "github.com/umbracle/go-web3"
+)
+
+// dynamicFeeTxType is the type of the transactions with EIP-1559 fees
+const dynamicFeeTxType = 0x02
+
+// Transaction is a transaction signed with the chain id. It is a legacy
+// transaction (EIP-155) with GasPrice or, if MaxFeePerGas is set, a
+// dynamic fee transaction (EIP-1559).
+type Transaction struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       web3.Address
+	Value    *big.Int
+	Data     []byte
+	ChainID  *big.Int
+
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// IsDynamicFee returns whether the transaction uses EIP-1559 fees
+func (t *Transaction) IsDynamicFee() bool {
+	return t.MaxFeePerGas != nil
+}
+
+// SignTx signs the transaction and returns its raw encoding, which is sent
+// with eth_sendRawTransaction, and its hash
+func (k *Key) SignTx(t *Transaction) ([]byte, web3.Hash) {
+	chainID := t.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+
+	var raw []byte
+	if t.IsDynamicFee() {
+		// the access list is always empty
+		fields := rlpList{chainID, t.Nonce, t.MaxPriorityFeePerGas, t.MaxFeePerGas, t.Gas, t.To[:], t.Value, t.Data, rlpList{}}
+		r, s, recid := k.Sign(keccak256([]byte{dynamicFeeTxType}, encodeRLP(fields)))
+
+		raw = append([]byte{dynamicFeeTxType}, encodeRLP(append(fields, uint64(recid), r, s))...)
+	} else {
+		fields := rlpList{t.Nonce, t.GasPrice, t.Gas, t.To[:], t.Value, t.Data}
+		r, s, recid := k.Sign(keccak256(encodeRLP(append(fields, chainID, uint64(0), uint64(0)))))
+
+		// v = recid + chainID * 2 + 35
+		v := new(big.Int).Lsh(chainID, 1)
+		v.Add(v, big.NewInt(int64(recid)+35))
+
+		raw = encodeRLP(append(fields, v, r, s))
+	}
+
+	var hash web3.Hash
+	copy(hash[:], keccak256(raw))
+	return raw, hash
+}
@@ -0,0 +1,284 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	web3 "github.com/umbracle/go-web3"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/umbracle/heura/helper/hex"
+)
+
+func TestKeyAddress(t *testing.T) {
+	cases := []struct {
+		key     string
+		address string
+	}{
+		{"0x4646464646464646464646464646464646464646464646464646464646464646", "0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"},
+		{"7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d", "0x008aeeda4d805471df9b2a5b0f38a0c3bcba786b"},
+		{"0x0000000000000000000000000000000000000000000000000000000000000001", "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf"},
+	}
+
+	for _, c := range cases {
+		key, err := NewKeyFromHex(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := key.Address()
+		if addr := hex.EncodeToHex(addr[:]); addr != c.address {
+			t.Errorf("%s: expected address %s but found %s", c.key, c.address, addr)
+		}
+	}
+
+	for _, key := range []string{"0x01", "0x" + strings.Repeat("00", 32), "0x" + strings.Repeat("ff", 32), "0xzz"} {
+		if _, err := NewKeyFromHex(key); err == nil {
+			t.Errorf("%s: expected error", key)
+		}
+	}
+}
+
+func TestRLP(t *testing.T) {
+	cases := []struct {
+		item     interface{}
+		expected string
+	}{
+		{[]byte{}, "0x80"},
+		{[]byte{0x0f}, "0x0f"},
+		{[]byte{0x80}, "0x8180"},
+		{[]byte("dog"), "0x83646f67"},
+		{uint64(0), "0x80"},
+		{uint64(1024), "0x820400"},
+		{(*big.Int)(nil), "0x80"},
+		{rlpList{}, "0xc0"},
+		{rlpList{[]byte("cat"), []byte("dog")}, "0xc88363617483646f67"},
+		{rlpList{rlpList{}, rlpList{rlpList{}}, rlpList{rlpList{}, rlpList{rlpList{}}}}, "0xc7c0c1c0c3c0c1c0"},
+		{[]byte("Lorem ipsum dolor sit amet, consectetur adipisicing elit"), "0xb8384c6f72656d20697073756d20646f6c6f722073697420616d65742c20636f6e7365637465747572206164697069736963696e6720656c6974"},
+	}
+
+	for _, c := range cases {
+		if res := hex.EncodeToHex(encodeRLP(c.item)); res != c.expected {
+			t.Errorf("%v: expected %s but found %s", c.item, c.expected, res)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// signatures with the nonces of RFC 6979
+	cases := []struct {
+		key   string
+		hash  string
+		r     string
+		s     string
+		recid byte
+	}{
+		{
+			"0x0000000000000000000000000000000000000000000000000000000000000001",
+			"0xc301ba9de5d6053caad9f5eb46523f007702add2c62fa39de03146a36b8026b7",
+			"0xc6c4137b0e5fbfc88ae3f293d7e80c8566c43ae20340075d44f75b009c943d09",
+			"0xba213513572e35943d5acdd17215561b03f11663192a7252196cc8b2a99560",
+			0,
+		},
+		{
+			"0x0000000000000000000000000000000000000000000000000000000000000002",
+			"0xc301ba9de5d6053caad9f5eb46523f007702add2c62fa39de03146a36b8026b7",
+			"0xe6f137b52377250760cc702e19b7aee3c63b0e7d95a91939b14ab3b5c4771e59",
+			"0x44b9bc4620afa158b7efdfea5234ff2d5f2f78b42886f02cf581827ee55318ea",
+			1,
+		},
+		{
+			"0x0000000000000000000000000000000000000000000000000000000000000001",
+			"0xdc063eba3c8d52a159e725c1a161506f6cb6b53478ad5ef3f08d534efa871d9f",
+			"0xdda8308cdbda2edf51ccf598b42b42b19597e102eb2ed4a04a16dd57084d3b40",
+			"0x0b6d67bab4929624e28f690407a15efc551354544fdc179970ff401eec2e5dc9",
+			1,
+		},
+		{
+			"0x0000000000000000000000000000000000000000000000000000000000000002",
+			"0xdc063eba3c8d52a159e725c1a161506f6cb6b53478ad5ef3f08d534efa871d9f",
+			"0x122663fd29e41a132d3c8329cf05d61ebcca9351074cc277dcd868faba58d87d",
+			"0x353a44f2d949c04981e4e4d9c1f93a9e0644e63a5eaa188288c5ad68fd288d40",
+			0,
+		},
+	}
+
+	for _, c := range cases {
+		key, err := NewKeyFromHex(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, s, recid := key.Sign(hex.MustDecodeHex(c.hash))
+		if res := hex.EncodeToHex(r.Bytes()); res != c.r {
+			t.Errorf("%s: expected r %s but found %s", c.hash, c.r, res)
+		}
+		if res := hex.EncodeToHex(s.Bytes()); res != c.s {
+			t.Errorf("%s: expected s %s but found %s", c.hash, c.s, res)
+		}
+		if recid != c.recid {
+			t.Errorf("%s: expected recovery id %d but found %d", c.hash, c.recid, recid)
+		}
+	}
+}
+
+func TestSignTx(t *testing.T) {
+	// example of EIP-155
+	key, err := NewKeyFromHex("0x4646464646464646464646464646464646464646464646464646464646464646")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, _ := new(big.Int).SetString("1000000000000000000", 10)
+	tx := &Transaction{
+		Nonce:    9,
+		GasPrice: big.NewInt(20000000000),
+		Gas:      21000,
+		To:       web3.HexToAddress("0x3535353535353535353535353535353535353535"),
+		Value:    value,
+		ChainID:  big.NewInt(1),
+	}
+
+	raw, hash := key.SignTx(tx)
+
+	expected := "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+	if res := hex.EncodeToHex(raw); res != expected {
+		t.Fatalf("expected %s but found %s", expected, res)
+	}
+	if hash.String() != "0x33469b22e9f636356c4160a87eb19df52b7412e8eac32a4a55ffe88ea8350788" {
+		t.Fatalf("bad hash %s", hash.String())
+	}
+}
+
+func TestDecryptKeystore(t *testing.T) {
+	// test vectors of the Web3 Secret Storage Definition
+	pbkdf2 := `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {"c": 262144, "dklen": 32, "prf": "hmac-sha256", "salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+
+	key, err := DecryptKeystore([]byte(pbkdf2), "testpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := key.Address()
+	if addr := hex.EncodeToHex(addr[:]); addr != "0x008aeeda4d805471df9b2a5b0f38a0c3bcba786b" {
+		t.Fatalf("bad address %s", addr)
+	}
+
+	if _, err := DecryptKeystore([]byte(pbkdf2), "wrong"); err == nil || err.Error() != "could not decrypt keystore with the given password" {
+		t.Fatalf("expected password error but found %v", err)
+	}
+	if _, err := DecryptKeystore([]byte(strings.Replace(pbkdf2, `"version": 3`, `"version": 1`, 1)), "testpassword"); err == nil {
+		t.Fatal("expected version error")
+	}
+}
+
+func TestDecryptKeystoreScrypt(t *testing.T) {
+	priv := hex.MustDecodeHex("0x7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d")
+	salt := hex.MustDecodeHex("0xab0c7876052600dd703518d6fc3fe8984592145b591fc8fb5c6d43190334ba19")
+	iv := hex.MustDecodeHex("0x83dbcc02d8ccb40e466191a123791e0e")
+
+	// encrypt the key with cheap scrypt parameters
+	derived, err := scrypt.Key([]byte("testpassword"), salt, 1024, 8, 1, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := aes.NewCipher(derived[:16])
+	cipherText := make([]byte, len(priv))
+	cipher.NewCTR(block, iv).XORKeyStream(cipherText, priv)
+	mac := keccak256(derived[16:32], cipherText)
+
+	data := fmt.Sprintf(`{
+		"address": "008aeeda4d805471df9b2a5b0f38a0c3bcba786b",
+		"Crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "%x"},
+			"ciphertext": "%x",
+			"kdf": "scrypt",
+			"kdfparams": {"n": 1024, "r": 8, "p": 1, "dklen": 32, "salt": "%x"},
+			"mac": "%x"
+		},
+		"version": 3
+	}`, iv, cipherText, salt, mac)
+
+	key, err := DecryptKeystore([]byte(data), "testpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := key.Address()
+	if addr := hex.EncodeToHex(addr[:]); addr != "0x008aeeda4d805471df9b2a5b0f38a0c3bcba786b" {
+		t.Fatalf("bad address %s", addr)
+	}
+
+	other := strings.Replace(data, "008aeeda4d805471df9b2a5b0f38a0c3bcba786b", "9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f", 1)
+	if _, err := DecryptKeystore([]byte(other), "testpassword"); err == nil {
+		t.Fatal("expected address mismatch")
+	}
+}
+
+func TestSignDynamicFeeTx(t *testing.T) {
+	key, err := NewKeyFromHex("0x4646464646464646464646464646464646464646464646464646464646464646")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &Transaction{
+		Nonce:                9,
+		Gas:                  21000,
+		To:                   web3.HexToAddress("0x3535353535353535353535353535353535353535"),
+		Value:                big.NewInt(1),
+		Data:                 []byte{0x01, 0x02},
+		ChainID:              big.NewInt(5),
+		MaxFeePerGas:         big.NewInt(30000000000),
+		MaxPriorityFeePerGas: big.NewInt(2000000000),
+	}
+	raw, hash := key.SignTx(tx)
+
+	if raw[0] != 0x02 {
+		t.Fatalf("expected type 2 but found %d", raw[0])
+	}
+	if hash.String() != hex.EncodeToHex(keccak256(raw)) {
+		t.Fatalf("bad hash %s", hash.String())
+	}
+
+	fields := rlpList{big.NewInt(5), uint64(9), big.NewInt(2000000000), big.NewInt(30000000000), uint64(21000), tx.To[:], big.NewInt(1), []byte{0x01, 0x02}, rlpList{}}
+	signingHash := keccak256([]byte{0x02}, encodeRLP(fields))
+	r, s, recid := key.Sign(signingHash)
+
+	expected := append([]byte{0x02}, encodeRLP(append(fields, uint64(recid), r, s))...)
+	if hex.EncodeToHex(raw) != hex.EncodeToHex(expected) {
+		t.Fatalf("expected %x but found %x", expected, raw)
+	}
+
+	// the signature recovers the key
+	sig := append([]byte{27 + recid}, append(leftPad(r.Bytes()), leftPad(s.Bytes())...)...)
+	pub, _, err := ecdsa.RecoverCompact(sig, signingHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.IsEqual(key.priv.PubKey()) {
+		t.Fatal("signature does not recover the public key")
+	}
+	var scalar secp256k1.ModNScalar
+	scalar.SetByteSlice(s.Bytes())
+	if scalar.IsOverHalfOrder() {
+		t.Fatal("s is not low")
+	}
+}
+
+func leftPad(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}